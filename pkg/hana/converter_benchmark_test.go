@@ -0,0 +1,114 @@
+package hana
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
+)
+
+const benchmarkRows = 1000000
+
+// legacyConverters reproduces the former string round-trip conversion of BIGINT, DOUBLE and TIMESTAMP columns.
+func legacyConverters() []sqlutil.Converter {
+	return sqlutil.ToConverters(
+		sqlutil.StringConverter{
+			Name:          "handle BIGINT",
+			InputScanKind: reflect.Struct,
+			InputTypeName: "BIGINT",
+			Replacer: &sqlutil.StringFieldReplacer{
+				OutputFieldType: data.FieldTypeNullableInt64,
+				ReplaceFunc: func(in *string) (any, error) {
+					v, err := strconv.ParseInt(*in, 10, 64)
+					return &v, err
+				},
+			},
+		},
+		sqlutil.StringConverter{
+			Name:          "handle DOUBLE",
+			InputScanKind: reflect.Struct,
+			InputTypeName: "DOUBLE",
+			Replacer: &sqlutil.StringFieldReplacer{
+				OutputFieldType: data.FieldTypeNullableFloat64,
+				ReplaceFunc: func(in *string) (any, error) {
+					v, err := strconv.ParseFloat(*in, 64)
+					return &v, err
+				},
+			},
+		},
+		sqlutil.StringConverter{
+			Name:          "handle TIMESTAMP",
+			InputScanKind: reflect.Struct,
+			InputTypeName: "TIMESTAMP",
+			Replacer: &sqlutil.StringFieldReplacer{
+				OutputFieldType: data.FieldTypeNullableTime,
+				ReplaceFunc: func(in *string) (any, error) {
+					v, err := time.Parse("2006-01-02 15:04:05", *in)
+					return &v, err
+				},
+			},
+		},
+	)
+}
+
+// typedConverters picks the converters of the transformer for BIGINT, DOUBLE and TIMESTAMP columns.
+func typedConverters(b *testing.B) []sqlutil.Converter {
+	t := &hanaQueryResultTransformer{}
+	all := t.GetConverterList()
+	res := make([]sqlutil.Converter, 0, 3)
+	for _, typeName := range []string{"BIGINT", "DOUBLE", "TIMESTAMP"} {
+		for _, c := range all {
+			if c.InputTypeRegex != nil && c.InputTypeRegex.MatchString(typeName) {
+				res = append(res, c)
+				break
+			}
+		}
+	}
+	if len(res) != 3 {
+		b.Fatalf("missing converters, found %d", len(res))
+	}
+	return res
+}
+
+func BenchmarkLegacyStringConverters(b *testing.B) {
+	converters := legacyConverters()
+	ts := time.Date(2024, 5, 17, 10, 11, 12, 0, time.UTC)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		frame := sqlutil.NewFrame([]string{"i", "f", "t"}, converters...)
+		for i := 0; i < benchmarkRows; i++ {
+			// the driver formats every value into the sql.NullString scan destination
+			row := []interface{}{
+				&sql.NullString{String: strconv.FormatInt(int64(i), 10), Valid: true},
+				&sql.NullString{String: strconv.FormatFloat(float64(i)*1.5, 'g', -1, 64), Valid: true},
+				&sql.NullString{String: ts.Add(time.Duration(i) * time.Second).Format("2006-01-02 15:04:05"), Valid: true},
+			}
+			if err := sqlutil.Append(frame, row, converters...); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkTypedConverters(b *testing.B) {
+	converters := typedConverters(b)
+	ts := time.Date(2024, 5, 17, 10, 11, 12, 0, time.UTC)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		frame := sqlutil.NewFrame([]string{"i", "f", "t"}, converters...)
+		for i := 0; i < benchmarkRows; i++ {
+			row := []interface{}{
+				&sql.NullInt64{Int64: int64(i), Valid: true},
+				&sql.NullFloat64{Float64: float64(i) * 1.5, Valid: true},
+				&sql.NullTime{Time: ts.Add(time.Duration(i) * time.Second), Valid: true},
+			}
+			if err := sqlutil.Append(frame, row, converters...); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
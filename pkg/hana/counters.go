@@ -0,0 +1,52 @@
+package hana
+
+import (
+	"fmt"
+	"strings"
+)
+
+// counterWindow returns the window clause ordering the rows of every counter by time.
+func counterWindow(timeCol string, partitions []string) string {
+	if len(partitions) == 0 {
+		return fmt.Sprintf("OVER (ORDER BY %s)", timeCol)
+	}
+	return fmt.Sprintf("OVER (PARTITION BY %s ORDER BY %s)", strings.Join(partitions, ", "), timeCol)
+}
+
+// counterIncrease returns the increase of a cumulative counter since the previous row. A
+// value lower than its predecessor is a counter reset, the counter restarted from zero, so
+// the increase is the value itself. The first row of every counter has no increase.
+func counterIncrease(valueCol, window string) string {
+	prev := fmt.Sprintf("LAG(%s) %s", valueCol, window)
+	return fmt.Sprintf("CASE WHEN %[2]s IS NULL THEN NULL WHEN %[1]s >= %[2]s THEN %[1]s - %[2]s ELSE %[1]s END", valueCol, prev)
+}
+
+// counterMacro expands $__rate, $__increase and $__delta:
+//
+//	$__rate(value_col, time_col[, partition_col...])     increase per second
+//	$__increase(value_col, time_col[, partition_col...]) increase since the previous row
+//	$__delta(value_col, time_col[, partition_col...])    difference to the previous row, without reset handling
+//
+// The expressions use window functions, so they have to be aggregated, for example by
+// $__timeGroup, in an outer query.
+func counterMacro(name string, args []string) (string, error) {
+	if len(args) < 2 || args[0] == "" || args[1] == "" {
+		return "", fmt.Errorf("macro %v needs value column, time column and optional partition columns", name)
+	}
+	valueCol, timeCol, partitions := args[0], args[1], args[2:]
+	for _, p := range partitions {
+		if p == "" {
+			return "", fmt.Errorf("empty partition column in macro %v", name)
+		}
+	}
+	window := counterWindow(timeCol, partitions)
+
+	switch name {
+	case "__increase":
+		return counterIncrease(valueCol, window), nil
+	case "__rate":
+		return fmt.Sprintf("(%s) / NULLIF(SECONDS_BETWEEN(LAG(%s) %s, %s), 0)", counterIncrease(valueCol, window), timeCol, window, timeCol), nil
+	default:
+		return fmt.Sprintf("%[1]s - LAG(%[1]s) %[2]s", valueCol, window), nil
+	}
+}
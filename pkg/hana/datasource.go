@@ -6,14 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/big"
 	"regexp"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 
+	"encoding/hex"
 	"net/url"
 	"reflect"
-	"strconv"
 	"strings"
 	"time"
 
@@ -29,10 +28,23 @@ import (
 	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 )
 
+// Database type names reported by go-hdb, grouped by the type they are scanned into.
+var (
+	integerPattern = regexp.MustCompile(`^(TINYINT|SMALLINT|INTEGER|BIGINT)$`)
+	floatPattern   = regexp.MustCompile(`^(REAL|DOUBLE)$`)
+	decimalPattern = regexp.MustCompile(`^(DECIMAL|SMALLDECIMAL|FIXED\d{1,2})$`)
+	timePattern    = regexp.MustCompile(`^(DATE|TIME|TIMESTAMP|LONGDATE|SECONDDATE|DAYDATE|SECONDTIME)$`)
+	binaryPattern  = regexp.MustCompile(`^(BINARY|VARBINARY)$`)
+	spatialPattern = regexp.MustCompile(`^(STGEOMETRY|STPOINT)$`)
+)
+
 const (
-	dateFormat      = "2006-01-02"
-	dateTimeFormat1 = "2006-01-02 15:04:05"
-	dateTimeFormat2 = "2006-01-02T15:04:05Z"
+	// defaultMaxFrameBytes is the approximate memory budget of a single result frame.
+	defaultMaxFrameBytes = 256 * 1024 * 1024
+	// defaultLobMaxLength is the maximum number of bytes read per LOB value.
+	defaultLobMaxLength = 64 * 1024
+	// defaultMetadataCacheTTL is the number of seconds catalog listings are cached.
+	defaultMetadataCacheTTL = 300
 )
 
 // Make sure Datasource implements required interfaces. This is important to do
@@ -64,6 +76,9 @@ func NewInstanceSettings(logger log.Logger) datasource.InstanceFactoryFunc {
 			ConnMaxLifetime:         sqlCfg.DefaultMaxConnLifetimeSeconds,
 			SecureDSProxy:           false,
 			AllowCleartextPasswords: false,
+			MaxFrameBytes:           defaultMaxFrameBytes,
+			LobMaxLength:            defaultLobMaxLength,
+			MetadataCacheTTL:        defaultMetadataCacheTTL,
 		}
 
 		err = json.Unmarshal(settings.JSONData, &jsonData)
@@ -167,6 +182,9 @@ func NewInstanceSettings(logger log.Logger) datasource.InstanceFactoryFunc {
 			TimeColumnNames:   []string{"time", "time_sec"},
 			MetricColumnTypes: []string{"CHAR", "VARCHAR", "TINYTEXT", "TEXT", "MEDIUMTEXT", "LONGTEXT"},
 			RowLimit:          sqlCfg.RowLimit,
+			FrameByteLimit:    jsonData.MaxFrameBytes,
+			LobMaxLength:      jsonData.LobMaxLength,
+			MetadataCacheTTL:  time.Duration(jsonData.MetadataCacheTTL) * time.Second,
 		}
 
 		userFacingDefaultError, err := cfg.UserFacingDefaultError()
@@ -187,7 +205,7 @@ func NewInstanceSettings(logger log.Logger) datasource.InstanceFactoryFunc {
 		db.SetMaxIdleConns(config.DSInfo.JsonData.MaxIdleConns)
 		db.SetConnMaxLifetime(time.Duration(config.DSInfo.JsonData.ConnMaxLifetime) * time.Second)
 
-		return sqleng.NewQueryDataHandler(userFacingDefaultError, db, config, &rowTransformer, newHanaMacroEngine(logger, userFacingDefaultError), logger)
+		return sqleng.NewQueryDataHandler(userFacingDefaultError, db, config, &rowTransformer, newHanaMacroEngine(logger, userFacingDefaultError, sqleng.ParseLocation(jsonData.Timezone)), logger)
 	}
 }
 
@@ -296,276 +314,94 @@ func (t *hanaQueryResultTransformer) TransformQueryError(logger log.Logger, err
 
 	return err
 }
-func (t *hanaQueryResultTransformer) GetConverterList2() []sqlutil.Converter {
-
-	var fixedPattern = regexp.MustCompile(`FIXED\d{1,2}`)
+func (t *hanaQueryResultTransformer) GetConverterList2(opts *sqleng.ConverterOptions) []sqlutil.Converter {
+	converters := append(decimalConverters(opts), spatialConverters(opts)...)
+	return append(converters, lobConverters()...)
+}
 
+func (t *hanaQueryResultTransformer) GetConverterList() []sqlutil.Converter {
+	// For the HANA driver , we have these possible data types:
+	// https://help.sap.com/docs/HANA_SERVICE_CF/7c78579ce9b14a669c1f3295b0d8ca16/20a1569875191014b507cf392724b7eb.html.
+	// Every column is scanned into the nullable type go-hdb supports for it, so values
+	// are never formatted to a string and parsed back.
 	return []sqlutil.Converter{
 		{
-			Name: "handle DECIMAL",
-			// InputScanKind:  reflect.Slice,
-			// InputTypeName: "FIXED8",
-			InputTypeRegex: fixedPattern,
+			Name:           "handle integer",
+			InputScanType:  reflect.TypeOf(sql.NullInt64{}),
+			InputTypeRegex: integerPattern,
 			FrameConverter: sqlutil.FrameConverter{
-				FieldType: data.FieldTypeNullableFloat64,
+				FieldType: data.FieldTypeNullableInt64,
 				ConverterFunc: func(in interface{}) (interface{}, error) {
-					switch x := in.(type) {
-					case *driver.NullDecimal:
-						if x.Valid {
-							d := (*big.Rat)(x.Decimal)
-							f, _ := d.Float64()
-							return &f, nil
-						} else {
-							return nil, nil
-						}
-					case *driver.Decimal:
-						{
-							bigRat, err := x.Value()
-							if err != nil {
-								return nil, fmt.Errorf("value is not of type *big.Rat")
-							}
-							rat1, ok := bigRat.(*big.Rat)
-							if !ok {
-								return nil, fmt.Errorf("decimal: invalid data type %T", bigRat)
-							}
-							f, _ := rat1.Float64()
-							return &f, nil
-						}
-					}
-					return nil, fmt.Errorf("decimal: invalid data value")
-				},
-			},
-		},
-	}
-}
-func (t *hanaQueryResultTransformer) GetConverterList() []sqlutil.StringConverter {
-	// For the HANA driver , we have these possible data types:
-	// https://help.sap.com/docs/HANA_SERVICE_CF/7c78579ce9b14a669c1f3295b0d8ca16/20a1569875191014b507cf392724b7eb.html.
-	// Since by default, we convert all into String, we need only to handle the Numeric data types
-	return []sqlutil.StringConverter{
-		{
-			Name:           "handle DOUBLE",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "DOUBLE",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableFloat64,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := strconv.ParseFloat(*in, 64)
-					if err != nil {
-						return nil, err
+					v := in.(*sql.NullInt64)
+					if !v.Valid {
+						return (*int64)(nil), nil
 					}
-					return &v, nil
+					f := v.Int64
+					return &f, nil
 				},
 			},
 		},
 		{
-			Name:           "handle BIGINT",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "BIGINT",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableInt64,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := strconv.ParseInt(*in, 10, 64)
-					if err != nil {
-						return nil, err
-					}
-					return &v, nil
-				},
-			},
-		},
-		// {
-		// 	Name:           "handle DECIMAL",
-		// 	InputScanKind:  reflect.Slice,
-		// 	InputTypeName:  "DECIMAL",
-		// 	ConversionFunc: func(in *string) (*string, error) { return in, nil },
-		// 	Replacer: &sqlutil.StringFieldReplacer{
-		// 		OutputFieldType: data.FieldTypeNullableFloat64,
-		// 		ReplaceFunc: func(in *string) (any, error) {
-		// 			if in == nil {
-		// 				return nil, nil
-		// 			}
-		// 			v, err := strconv.ParseFloat(*in, 64)
-		// 			if err != nil {
-		// 				return nil, err
-		// 			}
-		// 			return &v, nil
-		// 		},
-		// 	},
-		// },
-		{
-			Name:           "handle DATETIME",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "DATETIME",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableTime,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := time.Parse(dateTimeFormat1, *in)
-					if err == nil {
-						return &v, nil
-					}
-					v, err = time.Parse(dateTimeFormat2, *in)
-					if err == nil {
-						return &v, nil
-					}
-
-					return nil, err
-				},
-			},
-		},
-		{
-			Name:           "handle DATE",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "DATE",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableTime,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := time.Parse(dateFormat, *in)
-					if err == nil {
-						return &v, nil
-					}
-					v, err = time.Parse(dateTimeFormat1, *in)
-					if err == nil {
-						return &v, nil
-					}
-					v, err = time.Parse(dateTimeFormat2, *in)
-					if err == nil {
-						return &v, nil
-					}
-					return nil, err
-				},
-			},
-		},
-		{
-			Name:           "handle TIMESTAMP",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "TIMESTAMP",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableTime,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := time.Parse(dateTimeFormat1, *in)
-					if err == nil {
-						return &v, nil
-					}
-					v, err = time.Parse(dateTimeFormat2, *in)
-					if err == nil {
-						return &v, nil
-					}
-					return nil, err
-				},
-			},
-		},
-		{
-			Name:           "handle YEAR",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "YEAR",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableInt64,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := strconv.ParseInt(*in, 10, 64)
-					if err != nil {
-						return nil, err
-					}
-					return &v, nil
-				},
-			},
-		},
-		{
-			Name:           "handle TINYINT",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "TINYINT",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableInt64,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := strconv.ParseInt(*in, 10, 64)
-					if err != nil {
-						return nil, err
+			Name:           "handle floating point",
+			InputScanType:  reflect.TypeOf(sql.NullFloat64{}),
+			InputTypeRegex: floatPattern,
+			FrameConverter: sqlutil.FrameConverter{
+				FieldType: data.FieldTypeNullableFloat64,
+				ConverterFunc: func(in interface{}) (interface{}, error) {
+					v := in.(*sql.NullFloat64)
+					if !v.Valid {
+						return (*float64)(nil), nil
 					}
-					return &v, nil
+					f := v.Float64
+					return &f, nil
 				},
 			},
 		},
 		{
-			Name:           "handle SMALLINT",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "SMALLINT",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableInt64,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := strconv.ParseInt(*in, 10, 64)
-					if err != nil {
-						return nil, err
+			Name:          "handle BOOLEAN",
+			InputScanType: reflect.TypeOf(sql.NullBool{}),
+			InputTypeName: "BOOLEAN",
+			FrameConverter: sqlutil.FrameConverter{
+				FieldType: data.FieldTypeNullableBool,
+				ConverterFunc: func(in interface{}) (interface{}, error) {
+					v := in.(*sql.NullBool)
+					if !v.Valid {
+						return (*bool)(nil), nil
 					}
-					return &v, nil
+					f := v.Bool
+					return &f, nil
 				},
 			},
 		},
 		{
-			Name:           "handle INT",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "INT",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableInt64,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := strconv.ParseInt(*in, 10, 64)
-					if err != nil {
-						return nil, err
+			Name:           "handle date and time",
+			InputScanType:  reflect.TypeOf(sql.NullTime{}),
+			InputTypeRegex: timePattern,
+			FrameConverter: sqlutil.FrameConverter{
+				FieldType: data.FieldTypeNullableTime,
+				ConverterFunc: func(in interface{}) (interface{}, error) {
+					v := in.(*sql.NullTime)
+					if !v.Valid {
+						return (*time.Time)(nil), nil
 					}
-					return &v, nil
+					f := v.Time
+					return &f, nil
 				},
 			},
 		},
 		{
-			Name:           "handle FLOAT",
-			InputScanKind:  reflect.Struct,
-			InputTypeName:  "FLOAT",
-			ConversionFunc: func(in *string) (*string, error) { return in, nil },
-			Replacer: &sqlutil.StringFieldReplacer{
-				OutputFieldType: data.FieldTypeNullableFloat64,
-				ReplaceFunc: func(in *string) (any, error) {
-					if in == nil {
-						return nil, nil
-					}
-					v, err := strconv.ParseFloat(*in, 64)
-					if err != nil {
-						return nil, err
+			Name:           "handle binary",
+			InputScanType:  reflect.TypeOf(driver.NullBytes{}),
+			InputTypeRegex: binaryPattern,
+			FrameConverter: sqlutil.FrameConverter{
+				FieldType: data.FieldTypeNullableString,
+				ConverterFunc: func(in interface{}) (interface{}, error) {
+					v := in.(*driver.NullBytes)
+					if !v.Valid {
+						return (*string)(nil), nil
 					}
-					return &v, nil
+					f := strings.ToUpper(hex.EncodeToString(v.Bytes))
+					return &f, nil
 				},
 			},
 		},
@@ -0,0 +1,147 @@
+package hana
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+
+	"github.com/SAP/go-hdb/driver"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
+	"github.com/vincent/sap-hanadb/pkg/hana/sqleng"
+)
+
+// maxInt64Digits is the largest decimal precision that always fits into an int64.
+const maxInt64Digits = 18
+
+var (
+	bigTen      = big.NewInt(10)
+	decimalType = reflect.TypeOf(driver.NullDecimal{})
+)
+
+// decimalConverters returns one converter per decimal column of the query, so the
+// representation can depend on the precision and scale of the column.
+func decimalConverters(opts *sqleng.ConverterOptions) []sqlutil.Converter {
+	var converters []sqlutil.Converter
+	for _, ct := range opts.ColumnTypes {
+		if !decimalPattern.MatchString(ct.DatabaseTypeName()) {
+			continue
+		}
+		precision, scale, ok := ct.DecimalSize()
+		// floating point decimals report an out of range scale
+		fixed := ok && scale >= 0 && precision <= maxInt64Digits && scale <= precision
+
+		converter := sqlutil.Converter{
+			Name:            "handle DECIMAL " + ct.Name(),
+			InputScanType:   decimalType,
+			InputColumnName: ct.Name(),
+		}
+		switch {
+		case opts.DecimalMode == sqleng.DecimalModeString:
+			converter.FrameConverter = decimalStringConverter()
+		case opts.DecimalMode == sqleng.DecimalModeInt64 && fixed && scale == 0:
+			converter.FrameConverter = decimalScaledConverter(0, opts)
+		case opts.DecimalMode == sqleng.DecimalModeScaled && fixed:
+			converter.FrameConverter = decimalScaledConverter(scale, opts)
+			if opts.FieldConfigs == nil {
+				opts.FieldConfigs = map[string]*data.FieldConfig{}
+			}
+			opts.FieldConfigs[ct.Name()] = &data.FieldConfig{
+				Description: fmt.Sprintf("Decimal value multiplied by 10^%d", scale),
+				Custom:      map[string]interface{}{"decimalScale": scale},
+			}
+		default:
+			converter.FrameConverter = decimalFloatConverter(opts)
+		}
+		converters = append(converters, converter)
+	}
+	return converters
+}
+
+// decimalRat returns the scanned decimal value, or nil for NULL.
+func decimalRat(in interface{}) (*big.Rat, error) {
+	switch x := in.(type) {
+	case *driver.NullDecimal:
+		if !x.Valid {
+			return nil, nil
+		}
+		return (*big.Rat)(x.Decimal), nil
+	case *driver.Decimal:
+		return (*big.Rat)(x), nil
+	}
+	return nil, fmt.Errorf("decimal: invalid data type %T", in)
+}
+
+func decimalFloatConverter(opts *sqleng.ConverterOptions) sqlutil.FrameConverter {
+	return sqlutil.FrameConverter{
+		FieldType: data.FieldTypeNullableFloat64,
+		ConverterFunc: func(in interface{}) (interface{}, error) {
+			r, err := decimalRat(in)
+			if err != nil || r == nil {
+				return (*float64)(nil), err
+			}
+			f, exact := r.Float64()
+			// most decimal fractions have no exact binary representation, so we only
+			// report a loss if the shortest decimal form of the float differs
+			if !exact {
+				if back, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64)); !ok || back.Cmp(r) != 0 {
+					opts.PrecisionLoss++
+				}
+			}
+			return &f, nil
+		},
+	}
+}
+
+func decimalScaledConverter(scale int64, opts *sqleng.ConverterOptions) sqlutil.FrameConverter {
+	factor := new(big.Int).Exp(bigTen, big.NewInt(scale), nil)
+	return sqlutil.FrameConverter{
+		FieldType: data.FieldTypeNullableInt64,
+		ConverterFunc: func(in interface{}) (interface{}, error) {
+			r, err := decimalRat(in)
+			if err != nil || r == nil {
+				return (*int64)(nil), err
+			}
+			num := new(big.Int).Mul(r.Num(), factor)
+			q, m := num.QuoRem(num, r.Denom(), new(big.Int))
+			if m.Sign() != 0 || !q.IsInt64() {
+				opts.PrecisionLoss++
+			}
+			v := q.Int64()
+			return &v, nil
+		},
+	}
+}
+
+func decimalStringConverter() sqlutil.FrameConverter {
+	return sqlutil.FrameConverter{
+		FieldType: data.FieldTypeNullableString,
+		ConverterFunc: func(in interface{}) (interface{}, error) {
+			r, err := decimalRat(in)
+			if err != nil || r == nil {
+				return (*string)(nil), err
+			}
+			s := exactDecimalString(r)
+			return &s, nil
+		},
+	}
+}
+
+// exactDecimalString formats a decimal without losing digits. HANA decimals always
+// have a power of ten denominator, so the number of fraction digits is finite.
+func exactDecimalString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	digits := 0
+	pow := big.NewInt(1)
+	m := new(big.Int)
+	for ; digits < 6200; digits++ {
+		if m.Mod(pow, r.Denom()).Sign() == 0 {
+			break
+		}
+		pow.Mul(pow, bigTen)
+	}
+	return r.FloatString(digits)
+}
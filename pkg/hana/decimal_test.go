@@ -0,0 +1,74 @@
+package hana
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SAP/go-hdb/driver"
+	"github.com/vincent/sap-hanadb/pkg/hana/sqleng"
+)
+
+func nullDecimal(s string) *driver.NullDecimal {
+	r, _ := new(big.Rat).SetString(s)
+	return &driver.NullDecimal{Decimal: (*driver.Decimal)(r), Valid: true}
+}
+
+func TestExactDecimalString(t *testing.T) {
+	for in, want := range map[string]string{
+		"12345678901234567890.12": "12345678901234567890.12",
+		"-0.5":                    "-0.5",
+		"42":                      "42",
+		"0.001":                   "0.001",
+	} {
+		r, _ := new(big.Rat).SetString(in)
+		if got := exactDecimalString(r); got != want {
+			t.Errorf("exactDecimalString(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestDecimalScaledConverter(t *testing.T) {
+	opts := &sqleng.ConverterOptions{DecimalMode: sqleng.DecimalModeScaled}
+	conv := decimalScaledConverter(2, opts)
+
+	v, err := conv.ConverterFunc(nullDecimal("1234.56"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *(v.(*int64)); got != 123456 {
+		t.Fatalf("got %d, want 123456", got)
+	}
+	if opts.PrecisionLoss != 0 {
+		t.Fatalf("unexpected precision loss %d", opts.PrecisionLoss)
+	}
+
+	if _, err := conv.ConverterFunc(nullDecimal("0.125")); err != nil {
+		t.Fatal(err)
+	}
+	if opts.PrecisionLoss != 1 {
+		t.Fatalf("expected precision loss to be reported, got %d", opts.PrecisionLoss)
+	}
+
+	v, err = conv.ConverterFunc(&driver.NullDecimal{})
+	if err != nil || v.(*int64) != nil {
+		t.Fatalf("expected nil for NULL, got %v, %v", v, err)
+	}
+}
+
+func TestDecimalFloatConverterReportsLoss(t *testing.T) {
+	opts := &sqleng.ConverterOptions{DecimalMode: sqleng.DecimalModeFloat64}
+	conv := decimalFloatConverter(opts)
+
+	if _, err := conv.ConverterFunc(nullDecimal("0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if opts.PrecisionLoss != 0 {
+		t.Fatalf("0.1 should round-trip, got loss %d", opts.PrecisionLoss)
+	}
+	if _, err := conv.ConverterFunc(nullDecimal("123456789012345678.99")); err != nil {
+		t.Fatal(err)
+	}
+	if opts.PrecisionLoss != 1 {
+		t.Fatalf("expected precision loss to be reported, got %d", opts.PrecisionLoss)
+	}
+}
@@ -0,0 +1,61 @@
+package hana
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxHistogramBuckets limits the size of the CASE expression generated by $__histogramBuckets.
+const maxHistogramBuckets = 1000
+
+func formatBound(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// histogramBuckets returns an expression computing the upper bound of the bucket of col,
+// for use as bucket_le column of the heatmap format.
+//
+// With min and max the range is split into n buckets of equal width by a CASE expression,
+// which can be used in GROUP BY; values outside the range count to the first or last bucket.
+// Without, the range of the selected rows is used, which needs window functions. HANA does
+// not allow them in GROUP BY, so the buckets are selected in a subquery and grouped by its
+// result column in an outer query.
+func histogramBuckets(args []string) (string, error) {
+	if len(args) != 2 && len(args) != 4 {
+		return "", fmt.Errorf("macro __histogramBuckets needs a column, the number of buckets and optionally min and max")
+	}
+	col := args[0]
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 || n > maxHistogramBuckets {
+		return "", fmt.Errorf("number of buckets must be between 1 and %d, got %v", maxHistogramBuckets, args[1])
+	}
+
+	if len(args) == 2 {
+		lower := fmt.Sprintf("MIN(%s) OVER ()", col)
+		upper := fmt.Sprintf("MAX(%s) OVER ()", col)
+		return fmt.Sprintf("CASE WHEN %[3]s = %[2]s THEN %[3]s ELSE %[2]s + GREATEST(LEAST(CEIL((%[1]s - %[2]s) * %[4]d / (%[3]s - %[2]s)), %[4]d), 1) * (%[3]s - %[2]s) / %[4]d END",
+			col, lower, upper, n), nil
+	}
+
+	lower, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		return "", fmt.Errorf("error parsing histogram minimum %v", args[2])
+	}
+	upper, err := strconv.ParseFloat(args[3], 64)
+	if err != nil || upper <= lower {
+		return "", fmt.Errorf("histogram maximum %v must be a number greater than the minimum", args[3])
+	}
+
+	var sb strings.Builder
+	sb.WriteString("CASE WHEN ")
+	sb.WriteString(col)
+	sb.WriteString(" IS NULL THEN NULL")
+	width := (upper - lower) / float64(n)
+	for i := 1; i < n; i++ {
+		bound := formatBound(lower + float64(i)*width)
+		fmt.Fprintf(&sb, " WHEN %s <= %s THEN %s", col, bound, bound)
+	}
+	fmt.Fprintf(&sb, " ELSE %s END", formatBound(upper))
+	return sb.String(), nil
+}
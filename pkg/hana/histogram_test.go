@@ -0,0 +1,61 @@
+package hana
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	got, err := histogramBuckets([]string{"LATENCY", "4", "0", "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CASE WHEN LATENCY IS NULL THEN NULL WHEN LATENCY <= 25 THEN 25 WHEN LATENCY <= 50 THEN 50 WHEN LATENCY <= 75 THEN 75 ELSE 100 END"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	got, err = histogramBuckets([]string{"LATENCY", "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "MIN(LATENCY) OVER ()") || !strings.Contains(got, "* 10 /") {
+		t.Fatalf("unexpected window expression %q", got)
+	}
+
+	for _, args := range [][]string{{"LATENCY"}, {"LATENCY", "0"}, {"LATENCY", "x"}, {"LATENCY", "0", "0", "1"}, {"LATENCY", "x", "0", "1"}, {"LATENCY", "4", "10", "5"}, {"LATENCY", "4", "a", "5"}} {
+		if _, err := histogramBuckets(args); err == nil {
+			t.Fatalf("expected an error for %v", args)
+		}
+	}
+}
+
+// The buckets are grouped by, so the macro with a range must not expand to window functions.
+// Without a range they are grouped in an outer query.
+func TestHistogramBucketsGroupBy(t *testing.T) {
+	engine := newHanaMacroEngine(log.DefaultLogger, "error", time.UTC)
+	query := &backend.DataQuery{JSON: []byte(`{}`)}
+	timeRange := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	sql := "SELECT $__histogramBuckets(LATENCY, 10, 0, 500) AS \"bucket_le\", COUNT(*) FROM REQUESTS GROUP BY $__histogramBuckets(LATENCY, 10, 0, 500)"
+	got, err := engine.Interpolate(query, timeRange, sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "OVER") {
+		t.Fatalf("window function in GROUP BY: %s", got)
+	}
+
+	sql = "SELECT \"bucket_le\", COUNT(*) FROM (SELECT $__histogramBuckets(LATENCY, 10) AS \"bucket_le\" FROM REQUESTS) GROUP BY \"bucket_le\""
+	got, err = engine.Interpolate(query, timeRange, sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outer := got[strings.LastIndex(got, ")"):]; !strings.Contains(got, "MAX(LATENCY) OVER ()") || strings.Contains(outer, "OVER") {
+		t.Fatalf("window function outside of the subquery: %s", got)
+	}
+}
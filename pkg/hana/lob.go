@@ -0,0 +1,122 @@
+package hana
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SAP/go-hdb/driver"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
+	"github.com/vincent/sap-hanadb/pkg/hana/sqleng"
+)
+
+// lobTruncatedMarker is appended to LOB values which were cut at the configured maximum length.
+const lobTruncatedMarker = "…[truncated]"
+
+var (
+	charLobPattern   = regexp.MustCompile(`^(CLOB|NCLOB|TEXT|BINTEXT|LOCATOR|NLOCATOR)$`)
+	binaryLobPattern = regexp.MustCompile(`^BLOB$`)
+
+	_ sqleng.LimitedScanner = (*hanaLob)(nil)
+)
+
+// hanaLob is a scan destination for LOB columns which keeps at most limit bytes
+// of the value in memory. The remaining content is read from the server but discarded.
+type hanaLob struct {
+	buf       bytes.Buffer
+	limit     int64
+	truncated bool
+	valid     bool
+}
+
+func (l *hanaLob) SetLimit(n int64) { l.limit = n }
+
+func (l *hanaLob) Truncated() bool { return l.truncated }
+
+// Scan implements the sql.Scanner interface.
+func (l *hanaLob) Scan(src any) error {
+	l.buf.Reset()
+	l.truncated = false
+	if src == nil {
+		l.valid = false
+		return nil
+	}
+	l.valid = true
+	return driver.ScanLobWriter(src, l)
+}
+
+// Write implements io.Writer and is called by the driver while the LOB is read.
+func (l *hanaLob) Write(p []byte) (int, error) {
+	n := len(p)
+	if l.limit > 0 {
+		room := l.limit - int64(l.buf.Len())
+		if room < int64(n) {
+			l.truncated = true
+			if room < 0 {
+				room = 0
+			}
+			p = p[:room]
+		}
+	}
+	l.buf.Write(p)
+	return n, nil
+}
+
+// text returns the character LOB content, cutting an incomplete trailing rune.
+func (l *hanaLob) text() string {
+	b := l.buf.Bytes()
+	if l.truncated {
+		for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size > 1 {
+				break
+			}
+			b = b[:len(b)-1]
+		}
+		return string(b) + lobTruncatedMarker
+	}
+	return string(b)
+}
+
+func lobConverters() []sqlutil.Converter {
+	return []sqlutil.Converter{
+		{
+			Name:           "handle character LOB",
+			InputScanType:  reflect.TypeOf(hanaLob{}),
+			InputTypeRegex: charLobPattern,
+			FrameConverter: sqlutil.FrameConverter{
+				FieldType: data.FieldTypeNullableString,
+				ConverterFunc: func(in interface{}) (interface{}, error) {
+					l := in.(*hanaLob)
+					if !l.valid {
+						return (*string)(nil), nil
+					}
+					s := l.text()
+					return &s, nil
+				},
+			},
+		},
+		{
+			Name:           "handle binary LOB",
+			InputScanType:  reflect.TypeOf(hanaLob{}),
+			InputTypeRegex: binaryLobPattern,
+			FrameConverter: sqlutil.FrameConverter{
+				FieldType: data.FieldTypeNullableString,
+				ConverterFunc: func(in interface{}) (interface{}, error) {
+					l := in.(*hanaLob)
+					if !l.valid {
+						return (*string)(nil), nil
+					}
+					s := strings.ToUpper(hex.EncodeToString(l.buf.Bytes()))
+					if l.truncated {
+						s += lobTruncatedMarker
+					}
+					return &s, nil
+				},
+			},
+		},
+	}
+}
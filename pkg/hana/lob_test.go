@@ -0,0 +1,42 @@
+package hana
+
+import (
+	"testing"
+)
+
+func TestHanaLobWriteLimit(t *testing.T) {
+	l := &hanaLob{}
+	l.SetLimit(5)
+	for _, chunk := range []string{"abc", "def", "ghi"} {
+		n, err := l.Write([]byte(chunk))
+		if err != nil || n != len(chunk) {
+			t.Fatalf("write %q: n=%d err=%v", chunk, n, err)
+		}
+	}
+	if !l.Truncated() {
+		t.Fatal("expected value to be truncated")
+	}
+	if got, want := l.text(), "abcde"+lobTruncatedMarker; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestHanaLobTextCutsIncompleteRune(t *testing.T) {
+	l := &hanaLob{}
+	l.SetLimit(4)
+	_, _ = l.Write([]byte("ab€"))
+	if got, want := l.text(), "ab"+lobTruncatedMarker; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestHanaLobUnlimited(t *testing.T) {
+	l := &hanaLob{}
+	_, _ = l.Write([]byte("hello world"))
+	if l.Truncated() {
+		t.Fatal("expected value not to be truncated")
+	}
+	if got := l.text(); got != "hello world" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
@@ -20,16 +21,32 @@ type hanaMacroEngine struct {
 	*sqleng.SQLMacroEngineBase
 	logger    log.Logger
 	userError string
+	// location is the time zone of the data source, time groups are aligned to it
+	location *time.Location
 }
 
-func newHanaMacroEngine(logger log.Logger, userFacingDefaultError string) sqleng.SQLMacroEngine {
+func newHanaMacroEngine(logger log.Logger, userFacingDefaultError string, location *time.Location) sqleng.SQLMacroEngine {
 	return &hanaMacroEngine{
 		SQLMacroEngineBase: sqleng.NewSQLMacroEngineBase(),
 		logger:             logger,
 		userError:          userFacingDefaultError,
+		location:           location,
 	}
 }
 
+// groupExpr rounds the epoch expression down to the step, aligned to the time zone
+// offset of the data source at the start of the time range.
+func (m *hanaMacroEngine) groupExpr(epoch string, timeRange backend.TimeRange, step string) string {
+	offset := int64(0)
+	if m.location != nil {
+		offset = sqleng.AlignOffset(m.location, timeRange.From)
+	}
+	if offset == 0 {
+		return fmt.Sprintf("%s DIV %s * %s", epoch, step, step)
+	}
+	return fmt.Sprintf("(%s + %d) DIV %s * %s - %d", epoch, offset, step, step, offset)
+}
+
 func (m *hanaMacroEngine) Interpolate(query *backend.DataQuery, timeRange backend.TimeRange, sql string) (string, error) {
 	matches := restrictedRegExp.FindAllStringSubmatch(sql, 1)
 	if len(matches) > 0 {
@@ -37,6 +54,11 @@ func (m *hanaMacroEngine) Interpolate(query *backend.DataQuery, timeRange backen
 		return "", fmt.Errorf("invalid query - %s", m.userError)
 	}
 
+	sql, err := interpolateValueMacros(sql)
+	if err != nil {
+		return "", err
+	}
+
 	// TODO: Handle error
 	rExp, _ := regexp.Compile(sExpr)
 	var macroError error
@@ -94,7 +116,7 @@ func (m *hanaMacroEngine) evaluateMacro(timeRange backend.TimeRange, query *back
 				return "", err
 			}
 		}
-		return fmt.Sprintf("UNIX_TIMESTAMP(%s) DIV %.0f * %.0f", args[0], interval.Seconds(), interval.Seconds()), nil
+		return m.groupExpr(fmt.Sprintf("UNIX_TIMESTAMP(%s)", args[0]), timeRange, fmt.Sprintf("%.0f", interval.Seconds())), nil
 	case "__timeGroupAlias":
 		tg, err := m.evaluateMacro(timeRange, query, "__timeGroup", args)
 		if err == nil {
@@ -129,13 +151,25 @@ func (m *hanaMacroEngine) evaluateMacro(timeRange backend.TimeRange, query *back
 				return "", err
 			}
 		}
-		return fmt.Sprintf("%s DIV %v * %v", args[0], interval.Seconds(), interval.Seconds()), nil
+		return m.groupExpr(args[0], timeRange, fmt.Sprintf("%v", interval.Seconds())), nil
 	case "__unixEpochGroupAlias":
 		tg, err := m.evaluateMacro(timeRange, query, "__unixEpochGroup", args)
 		if err == nil {
 			return tg + " AS \"time\"", nil
 		}
 		return "", err
+	case "__geoJson":
+		if len(args) == 0 {
+			return "", fmt.Errorf("missing spatial column argument for macro %v", name)
+		}
+		alias := args[0][strings.LastIndex(args[0], ".")+1:]
+		return fmt.Sprintf("%s.ST_AsGeoJSON() AS \"%s\"", args[0], strings.Trim(alias, `"`)), nil
+	case "__timeFilterShift", "__timeFromShift", "__timeToShift", "__timeShiftGroup":
+		return m.timeShiftMacro(timeRange, query, name, args)
+	case "__rate", "__increase", "__delta":
+		return counterMacro(name, args)
+	case "__histogramBuckets":
+		return histogramBuckets(args)
 	default:
 		return "", fmt.Errorf("unknown macro %v", name)
 	}
@@ -0,0 +1,110 @@
+package hana
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/vincent/sap-hanadb/pkg/hana/sqleng"
+)
+
+func TestTimeGroupTimezone(t *testing.T) {
+	query := &backend.DataQuery{JSON: []byte(`{}`)}
+	timeRange := backend.TimeRange{
+		From: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	utc := newHanaMacroEngine(log.DefaultLogger, "error", time.UTC)
+	got, err := utc.Interpolate(query, timeRange, "$__timeGroup(TS, '1h')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "UNIX_TIMESTAMP(TS) DIV 3600 * 3600"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	berlin := newHanaMacroEngine(log.DefaultLogger, "error", sqleng.ParseLocation("Europe/Berlin"))
+	got, err = berlin.Interpolate(query, timeRange, "$__timeGroup(TS, '1d', linear)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(UNIX_TIMESTAMP(TS) + 3600) DIV 86400 * 86400 - 3600"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if string(query.JSON) != `{"fill":true,"fillInterval":86400,"fillMode":"linear"}` {
+		t.Fatalf("unexpected fill parameters %s", query.JSON)
+	}
+}
+
+func TestCounterMacros(t *testing.T) {
+	got, err := counterMacro("__increase", []string{"CYCLES", "TS", "PLANT", "MACHINE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := "LAG(CYCLES) OVER (PARTITION BY PLANT, MACHINE ORDER BY TS)"
+	if want := "CASE WHEN " + prev + " IS NULL THEN NULL WHEN CYCLES >= " + prev + " THEN CYCLES - " + prev + " ELSE CYCLES END"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	got, err = counterMacro("__rate", []string{"CYCLES", "TS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NULLIF(SECONDS_BETWEEN(LAG(TS) OVER (ORDER BY TS), TS), 0)"; !strings.HasSuffix(got, want) {
+		t.Fatalf("got %q, want suffix %q", got, want)
+	}
+
+	got, err = counterMacro("__delta", []string{"LEVEL", "TS", "TANK"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "LEVEL - LAG(LEVEL) OVER (PARTITION BY TANK ORDER BY TS)"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if _, err := counterMacro("__rate", []string{"CYCLES"}); err == nil {
+		t.Fatal("expected an error without time column")
+	}
+}
+
+func TestTimeShiftMacros(t *testing.T) {
+	m := newHanaMacroEngine(log.DefaultLogger, "error", time.UTC)
+	query := &backend.DataQuery{JSON: []byte(`{}`)}
+	timeRange := backend.TimeRange{
+		From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{
+			sql:  "$__timeFilterShift(TS, '7d')",
+			want: "TS BETWEEN FROM_UNIXTIME(1708646400) AND FROM_UNIXTIME(1709251200)",
+		},
+		{
+			// one year before 2024-03-01 is 2023-03-01
+			sql:  "$__timeFromShift('1y')",
+			want: "FROM_UNIXTIME(1677628800)",
+		},
+		{
+			sql:  "$__timeShiftGroup(TS, '1h', '7d')",
+			want: "(UNIX_TIMESTAMP(TS) + 604800) DIV 3600 * 3600",
+		},
+	}
+	for _, tt := range tests {
+		got, err := m.Interpolate(query, timeRange, tt.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+	if _, err := m.Interpolate(query, timeRange, "$__timeFromShift('soon')"); err == nil {
+		t.Fatal("expected an error for an invalid shift")
+	}
+}
@@ -0,0 +1,329 @@
+package hana
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
+	"github.com/vincent/sap-hanadb/pkg/hana/sqleng"
+)
+
+// WKB geometry type codes, see the OGC simple features specification.
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+
+	// EWKB flags as used by HANA for geometries with a SRID or Z/M coordinates.
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+var (
+	pointPattern = regexp.MustCompile(`^STPOINT$`)
+
+	errWKBTruncated = errors.New("wkb: unexpected end of data")
+)
+
+// geoJSON is a GeoJSON geometry object.
+type geoJSON struct {
+	Type        string     `json:"type"`
+	Coordinates any        `json:"coordinates,omitempty"`
+	Geometries  []*geoJSON `json:"geometries,omitempty"`
+}
+
+type wkbReader struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.b) < 4 {
+		return 0, errWKBTruncated
+	}
+	v := r.order.Uint32(r.b)
+	r.b = r.b[4:]
+	return v, nil
+}
+
+func (r *wkbReader) float64() (float64, error) {
+	if len(r.b) < 8 {
+		return 0, errWKBTruncated
+	}
+	v := math.Float64frombits(r.order.Uint64(r.b))
+	r.b = r.b[8:]
+	return v, nil
+}
+
+func (r *wkbReader) coord(dims int) ([]float64, error) {
+	c := make([]float64, dims)
+	for i := range c {
+		f, err := r.float64()
+		if err != nil {
+			return nil, err
+		}
+		c[i] = f
+	}
+	// GeoJSON positions only carry x, y and z
+	if dims > 3 {
+		c = c[:3]
+	}
+	return c, nil
+}
+
+func (r *wkbReader) coords(dims int) ([][]float64, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if int(n)*dims*8 > len(r.b) {
+		return nil, errWKBTruncated
+	}
+	cs := make([][]float64, n)
+	for i := range cs {
+		if cs[i], err = r.coord(dims); err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
+
+func (r *wkbReader) rings(dims int) ([][][]float64, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if int(n)*4 > len(r.b) {
+		return nil, errWKBTruncated
+	}
+	rs := make([][][]float64, n)
+	for i := range rs {
+		if rs[i], err = r.coords(dims); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+// geometry decodes a single (E)WKB geometry including its byte order marker.
+func (r *wkbReader) geometry() (*geoJSON, error) {
+	if len(r.b) < 1 {
+		return nil, errWKBTruncated
+	}
+	switch r.b[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("wkb: invalid byte order %d", r.b[0])
+	}
+	r.b = r.b[1:]
+
+	typ, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	dims := 2
+	if typ&ewkbZ != 0 {
+		dims++
+	}
+	if typ&ewkbM != 0 {
+		dims++
+	}
+	if typ&ewkbSRID != 0 {
+		if _, err := r.uint32(); err != nil {
+			return nil, err
+		}
+	}
+	typ &^= ewkbZ | ewkbM | ewkbSRID
+	// ISO WKB encodes Z and M as offsets of the type code
+	switch typ / 1000 {
+	case 1, 2:
+		dims++
+	case 3:
+		dims += 2
+	}
+	typ %= 1000
+
+	switch typ {
+	case wkbPoint:
+		c, err := r.coord(dims)
+		if err != nil {
+			return nil, err
+		}
+		// an empty point is encoded with NaN coordinates
+		if math.IsNaN(c[0]) && math.IsNaN(c[1]) {
+			return &geoJSON{Type: "Point", Coordinates: []float64{}}, nil
+		}
+		return &geoJSON{Type: "Point", Coordinates: c}, nil
+	case wkbLineString:
+		cs, err := r.coords(dims)
+		return &geoJSON{Type: "LineString", Coordinates: cs}, err
+	case wkbPolygon:
+		rs, err := r.rings(dims)
+		return &geoJSON{Type: "Polygon", Coordinates: rs}, err
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+		n, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if int(n)*5 > len(r.b) {
+			return nil, errWKBTruncated
+		}
+		parts := make([]*geoJSON, n)
+		coords := make([]any, n)
+		for i := range parts {
+			if parts[i], err = r.geometry(); err != nil {
+				return nil, err
+			}
+			coords[i] = parts[i].Coordinates
+		}
+		switch typ {
+		case wkbMultiPoint:
+			return &geoJSON{Type: "MultiPoint", Coordinates: coords}, nil
+		case wkbMultiLineString:
+			return &geoJSON{Type: "MultiLineString", Coordinates: coords}, nil
+		case wkbMultiPolygon:
+			return &geoJSON{Type: "MultiPolygon", Coordinates: coords}, nil
+		default:
+			return &geoJSON{Type: "GeometryCollection", Geometries: parts}, nil
+		}
+	default:
+		return nil, fmt.Errorf("wkb: unsupported geometry type %d", typ)
+	}
+}
+
+// decodeWKB converts a (E)WKB geometry, as transferred by HANA for spatial columns, to GeoJSON.
+func decodeWKB(b []byte) (*geoJSON, error) {
+	r := &wkbReader{b: b}
+	return r.geometry()
+}
+
+// decodeHexWKB decodes the hex encoded WKB which go-hdb returns for spatial columns.
+func decodeHexWKB(s string) (*geoJSON, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("wkb: %w", err)
+	}
+	return decodeWKB(b)
+}
+
+// pointColumn collects the coordinates of a ST_POINT column while the rows are scanned,
+// so the column can be replaced by latitude and longitude fields afterwards.
+type pointColumn struct {
+	name      string
+	latitude  []*float64
+	longitude []*float64
+}
+
+// spatialConverters returns the converters of the spatial columns of the query. Points
+// become latitude/longitude fields, every other geometry a GeoJSON string.
+func spatialConverters(opts *sqleng.ConverterOptions) []sqlutil.Converter {
+	var points []*pointColumn
+	var converters []sqlutil.Converter
+	for _, ct := range opts.ColumnTypes {
+		typeName := ct.DatabaseTypeName()
+		if !spatialPattern.MatchString(typeName) {
+			continue
+		}
+		var pc *pointColumn
+		if pointPattern.MatchString(typeName) {
+			pc = &pointColumn{name: ct.Name()}
+			points = append(points, pc)
+		}
+		converters = append(converters, sqlutil.Converter{
+			Name:            "handle spatial " + ct.Name(),
+			InputScanType:   reflect.TypeOf(sql.NullString{}),
+			InputColumnName: ct.Name(),
+			FrameConverter:  geoJSONConverter(pc),
+		})
+	}
+	if len(points) > 0 {
+		opts.FrameTransforms = append(opts.FrameTransforms, func(frame *data.Frame) error {
+			return splitPointColumns(frame, points)
+		})
+	}
+	return converters
+}
+
+func geoJSONConverter(pc *pointColumn) sqlutil.FrameConverter {
+	return sqlutil.FrameConverter{
+		FieldType: data.FieldTypeNullableString,
+		ConverterFunc: func(in interface{}) (interface{}, error) {
+			v := in.(*sql.NullString)
+			var lat, lon *float64
+			if pc != nil {
+				defer func() {
+					pc.latitude = append(pc.latitude, lat)
+					pc.longitude = append(pc.longitude, lon)
+				}()
+			}
+			if !v.Valid {
+				return (*string)(nil), nil
+			}
+			g, err := decodeHexWKB(v.String)
+			if err != nil {
+				// keep geometries we cannot decode (e.g. circular strings) as they are
+				s := v.String
+				return &s, nil
+			}
+			if c, ok := g.Coordinates.([]float64); ok && g.Type == "Point" && len(c) >= 2 {
+				lon, lat = &c[0], &c[1]
+			}
+			b, err := json.Marshal(g)
+			if err != nil {
+				return nil, err
+			}
+			s := string(b)
+			return &s, nil
+		},
+	}
+}
+
+// splitPointColumns replaces every point column by a latitude and a longitude field.
+// A single point column produces fields named "latitude" and "longitude", which the
+// Geomap panel detects automatically.
+func splitPointColumns(frame *data.Frame, points []*pointColumn) error {
+	for _, pc := range points {
+		idx := -1
+		for i, field := range frame.Fields {
+			if field.Name == pc.name {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			continue
+		}
+		if len(pc.latitude) != frame.Fields[idx].Len() {
+			return fmt.Errorf("point column %q has %d coordinates for %d rows", pc.name, len(pc.latitude), frame.Fields[idx].Len())
+		}
+		latName, lonName := "latitude", "longitude"
+		if len(points) > 1 {
+			latName, lonName = pc.name+" latitude", pc.name+" longitude"
+		}
+		lat := data.NewField(latName, frame.Fields[idx].Labels, pc.latitude)
+		lon := data.NewField(lonName, frame.Fields[idx].Labels, pc.longitude)
+
+		fields := make([]*data.Field, 0, len(frame.Fields)+1)
+		fields = append(fields, frame.Fields[:idx]...)
+		fields = append(fields, lat, lon)
+		fields = append(fields, frame.Fields[idx+1:]...)
+		frame.Fields = fields
+	}
+	return nil
+}
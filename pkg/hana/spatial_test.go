@@ -0,0 +1,56 @@
+package hana
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeHexWKB(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want string
+	}{
+		{
+			name: "point",
+			hex:  "0101000000000000000000F03F0000000000000040",
+			want: `{"type":"Point","coordinates":[1,2]}`,
+		},
+		{
+			name: "ewkb point with srid",
+			hex:  "0101000020E6100000000000000000244000000000000034C0",
+			want: `{"type":"Point","coordinates":[10,-20]}`,
+		},
+		{
+			name: "big endian linestring",
+			hex:  "0000000002000000023FF000000000000040000000000000004008000000000000" + "4010000000000000",
+			want: `{"type":"LineString","coordinates":[[1,2],[3,4]]}`,
+		},
+		{
+			name: "multipoint",
+			hex:  "0104000000020000000101000000000000000000F03F0000000000000040010100000000000000000008400000000000001040",
+			want: `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := decodeHexWKB(tt.hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Fatalf("got %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHexWKBTruncated(t *testing.T) {
+	if _, err := decodeHexWKB("0101000000000000000000F03F"); err == nil {
+		t.Fatal("expected an error for truncated data")
+	}
+}
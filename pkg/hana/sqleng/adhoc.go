@@ -0,0 +1,203 @@
+package sqleng
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdhocFilter is a filter of a Grafana ad hoc filter variable.
+type AdhocFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	// Values holds the values of the one of operators.
+	Values []string `json:"values,omitempty"`
+}
+
+// Ad hoc filter operators as sent by Grafana.
+var adhocOperators = map[string]string{
+	"=":   "=",
+	"!=":  "<>",
+	"<":   "<",
+	">":   ">",
+	"=~":  "LIKE_REGEXPR",
+	"!~":  "NOT LIKE_REGEXPR",
+	"=|":  "IN",
+	"!=|": "NOT IN",
+}
+
+var (
+	adhocNumericTypes   = regexp.MustCompile(`^(TINYINT|SMALLINT|INTEGER|BIGINT|DECIMAL|SMALLDECIMAL|REAL|DOUBLE|FLOAT|FIXED\d{1,2})$`)
+	adhocTimestampTypes = regexp.MustCompile(`^(TIMESTAMP|LONGDATE|SECONDDATE)$`)
+	adhocDateTypes      = regexp.MustCompile(`^(DATE|DAYDATE)$`)
+	adhocTimeTypes      = regexp.MustCompile(`^(TIME|SECONDTIME)$`)
+	// decimalLiteral is a number HANA reads as numeric literal, unlike Inf, NaN or 0x1p-2
+	decimalLiteral = regexp.MustCompile(`^[+-]?\d+(\.\d+)?([eE][+-]?\d+)?$`)
+)
+
+func (f AdhocFilter) validate() error {
+	if f.Key == "" {
+		return fmt.Errorf("ad hoc filter without key")
+	}
+	if _, ok := adhocOperators[f.Operator]; !ok {
+		return fmt.Errorf("unsupported ad hoc filter operator %q", f.Operator)
+	}
+	return nil
+}
+
+// adhocColumn is a result column of the filtered query.
+type adhocColumn struct {
+	name     string
+	typeName string
+}
+
+// quoteIdentifier quotes a HANA identifier.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteString returns a HANA unicode string literal.
+func quoteString(s string) string {
+	return "N'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// typedLiteral renders a filter value as literal of the column type, so HANA compares
+// numbers and dates by value instead of converting the column to a string. Dates and
+// timestamps without a zone are read in loc, the timezone of the data source.
+func typedLiteral(typeName, value string, loc *time.Location) (string, error) {
+	v := strings.TrimSpace(value)
+	switch {
+	case adhocNumericTypes.MatchString(typeName):
+		if !decimalLiteral.MatchString(v) {
+			return "", fmt.Errorf("value %q is not a number", value)
+		}
+		return v, nil
+	case typeName == "BOOLEAN":
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", fmt.Errorf("value %q is not a boolean", value)
+		}
+		return strings.ToUpper(strconv.FormatBool(b)), nil
+	case adhocTimestampTypes.MatchString(typeName), adhocDateTypes.MatchString(typeName):
+		t, err := parseSAPTime(v, loc)
+		if err != nil || t == nil {
+			return "", fmt.Errorf("value %q is not a date", value)
+		}
+		// epochs are instants, HANA compares the literal with the local values of the column
+		local := t.In(loc)
+		if adhocDateTypes.MatchString(typeName) {
+			return "DATE'" + local.Format("2006-01-02") + "'", nil
+		}
+		return "TIMESTAMP'" + local.Format("2006-01-02 15:04:05.000000") + "'", nil
+	case adhocTimeTypes.MatchString(typeName):
+		t, err := time.Parse("15:04:05", v)
+		if err != nil {
+			return "", fmt.Errorf("value %q is not a time", value)
+		}
+		return "TIME'" + t.Format("15:04:05") + "'", nil
+	}
+	return quoteString(value), nil
+}
+
+// findAdhocColumn returns the result column of a filter key. Keys are matched exactly first,
+// then case-insensitively, as HANA returns unquoted names in upper case.
+func findAdhocColumn(columns []adhocColumn, key string) (adhocColumn, error) {
+	for _, c := range columns {
+		if c.name == key {
+			return c, nil
+		}
+	}
+	var match *adhocColumn
+	for i, c := range columns {
+		if strings.EqualFold(c.name, key) {
+			if match != nil {
+				return adhocColumn{}, fmt.Errorf("ad hoc filter key %q matches several columns", key)
+			}
+			match = &columns[i]
+		}
+	}
+	if match == nil {
+		return adhocColumn{}, fmt.Errorf("ad hoc filter key %q is not a column of the query result", key)
+	}
+	return *match, nil
+}
+
+// adhocCondition renders a single filter as condition on the result column.
+func adhocCondition(f AdhocFilter, col adhocColumn, loc *time.Location) (string, error) {
+	op := adhocOperators[f.Operator]
+	ident := quoteIdentifier(col.name)
+	switch f.Operator {
+	case "=~", "!~":
+		return fmt.Sprintf("%s %s %s", ident, op, quoteString(f.Value)), nil
+	case "=|", "!=|":
+		values := f.Values
+		if len(values) == 0 {
+			values = strings.Split(f.Value, ",")
+		}
+		literals := make([]string, len(values))
+		for i, v := range values {
+			l, err := typedLiteral(col.typeName, v, loc)
+			if err != nil {
+				return "", err
+			}
+			literals[i] = l
+		}
+		return fmt.Sprintf("%s %s (%s)", ident, op, strings.Join(literals, ", ")), nil
+	}
+	l, err := typedLiteral(col.typeName, f.Value, loc)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", ident, op, l), nil
+}
+
+// adhocSubquery wraps the query, so filters can refer to its result columns.
+func adhocSubquery(sql string) string {
+	sql = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(sql), ";"))
+	// the line break ends a trailing line comment of the user query
+	return "SELECT * FROM (\n" + sql + "\n) AS \"ADHOC\""
+}
+
+// adhocQuery returns the query with the filters applied as WHERE clause of the wrapped query.
+func adhocQuery(sql string, filters []AdhocFilter, columns []adhocColumn, loc *time.Location) (string, error) {
+	conditions := make([]string, 0, len(filters))
+	for _, f := range filters {
+		col, err := findAdhocColumn(columns, f.Key)
+		if err != nil {
+			return "", err
+		}
+		cond, err := adhocCondition(f, col, loc)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, cond)
+	}
+	return adhocSubquery(sql) + " WHERE " + strings.Join(conditions, " AND "), nil
+}
+
+// applyAdhocFilters reads the result columns of the query without fetching rows and
+// returns the query filtered by the ad hoc filters.
+func (e *DataSourceHandler) applyAdhocFilters(ctx context.Context, sql string, filters []AdhocFilter) (string, error) {
+	rows, err := e.db.QueryContext(ctx, adhocSubquery(sql)+" LIMIT 0")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			e.log.FromContext(ctx).Warn("Failed to close rows", "err", err)
+		}
+	}()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return "", err
+	}
+	columns := make([]adhocColumn, len(types))
+	for i, t := range types {
+		columns[i] = adhocColumn{name: t.Name(), typeName: t.DatabaseTypeName()}
+	}
+	return adhocQuery(sql, filters, columns, e.location())
+}
@@ -0,0 +1,66 @@
+package sqleng
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdhocQuery(t *testing.T) {
+	columns := []adhocColumn{
+		{name: "PLANT", typeName: "NVARCHAR"},
+		{name: "QTY", typeName: "DECIMAL"},
+		{name: "POSTED", typeName: "TIMESTAMP"},
+		{name: "Mixed Case", typeName: "NVARCHAR"},
+	}
+	filters := []AdhocFilter{
+		{Key: "plant", Operator: "=", Value: "O'Neil"},
+		{Key: "QTY", Operator: ">", Value: "10.5"},
+		{Key: "POSTED", Operator: "<", Value: "2024-01-31 08:00:00"},
+		{Key: "Mixed Case", Operator: "=~", Value: "^A.*"},
+		{Key: "PLANT", Operator: "=|", Values: []string{"1000", "2000"}},
+	}
+	got, err := adhocQuery("SELECT * FROM T -- comment\n;", filters, columns, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT * FROM (\nSELECT * FROM T -- comment\n) AS \"ADHOC\" WHERE " +
+		`"PLANT" = N'O''Neil' AND "QTY" > 10.5 AND "POSTED" < TIMESTAMP'2024-01-31 08:00:00.000000' AND ` +
+		`"Mixed Case" LIKE_REGEXPR N'^A.*' AND "PLANT" IN (N'1000', N'2000')`
+	if got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = adhocQuery("SELECT * FROM T", []AdhocFilter{
+		{Key: "POSTED", Operator: ">", Value: "2024-01-31 08:00:00"},
+		{Key: "POSTED", Operator: "<", Value: "1706695200000"},
+	}, columns, berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "SELECT * FROM (\nSELECT * FROM T\n) AS \"ADHOC\" WHERE " +
+		`"POSTED" > TIMESTAMP'2024-01-31 08:00:00.000000' AND "POSTED" < TIMESTAMP'2024-01-31 11:00:00.000000'`
+	if got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+
+	for _, f := range []AdhocFilter{
+		{Key: "MISSING", Operator: "=", Value: "1"},
+		{Key: "QTY", Operator: "=", Value: "1; DROP TABLE T"},
+		{Key: "QTY", Operator: "=", Value: "Inf"},
+		{Key: "QTY", Operator: "=", Value: "NaN"},
+		{Key: "QTY", Operator: "=", Value: "0x1p-2"},
+		{Key: "QTY", Operator: "=", Value: "1_000"},
+		{Key: "POSTED", Operator: "=", Value: "yesterday"},
+	} {
+		if _, err := adhocQuery("SELECT 1 FROM DUMMY", []AdhocFilter{f}, columns, time.UTC); err == nil {
+			t.Fatalf("expected an error for %+v", f)
+		}
+	}
+	if _, err := ParseQueryJson([]byte(`{"rawSql":"select 1","format":"table","adhocFilters":[{"key":"A","operator":"<>","value":"1"}]}`)); err == nil {
+		t.Fatal("expected an error for an unknown operator")
+	}
+}
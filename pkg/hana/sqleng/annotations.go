@@ -0,0 +1,134 @@
+package sqleng
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Column names recognised by the annotations format, compared case-insensitively.
+var (
+	annotationTimeColumnNames    = []string{"time", "time_sec", "timestamp", "start_time", "starttime"}
+	annotationTimeEndColumnNames = []string{"timeend", "time_end", "end_time", "endtime"}
+	annotationTextColumnNames    = []string{"text", "description", "message"}
+	annotationTitleColumnNames   = []string{"title"}
+
+	// tag columns are named tags or tag, optionally followed by a number or an underscore suffix
+	annotationTagColumnPattern = regexp.MustCompile(`(?i)^tags?(\d+|_\w+)?$`)
+)
+
+// convertToAnnotationsFrame reshapes a result into the frame read by Grafana annotations:
+// time, timeEnd, title, text and tags, followed by the remaining columns. Time columns may
+// hold native timestamps, epochs or SAP DATS/TIMS values; tags are merged from all tag
+// columns and split at commas.
+func convertToAnnotationsFrame(frame *data.Frame, loc *time.Location) (*data.Frame, error) {
+	timeIdx := findFieldByName(frame, annotationTimeColumnNames, nil)
+	if timeIdx == -1 {
+		return nil, errors.New("time column is missing; make sure your data includes a column named time for annotations format")
+	}
+	timeEndIdx := findFieldByName(frame, annotationTimeEndColumnNames, nil)
+	textIdx := findFieldByName(frame, annotationTextColumnNames, nil)
+	titleIdx := findFieldByName(frame, annotationTitleColumnNames, nil)
+
+	var tagFields, extraFields []*data.Field
+	for i, f := range frame.Fields {
+		switch {
+		case i == timeIdx || i == timeEndIdx || i == textIdx || i == titleIdx:
+		case annotationTagColumnPattern.MatchString(f.Name):
+			tagFields = append(tagFields, f)
+		default:
+			extraFields = append(extraFields, f)
+		}
+	}
+
+	rows := frame.Rows()
+	times := make([]time.Time, 0, rows)
+	timeEnds := make([]*time.Time, 0, rows)
+	texts := make([]string, 0, rows)
+	titles := make([]string, 0, rows)
+	tags := make([]string, 0, rows)
+	extras := make([]*data.Field, len(extraFields))
+	for i, f := range extraFields {
+		extras[i] = data.NewFieldFromFieldType(f.Type(), 0)
+		extras[i].Name = f.Name
+		extras[i].Labels = f.Labels
+		extras[i].Config = f.Config
+	}
+
+	skipped := 0
+	for i := 0; i < rows; i++ {
+		t, err := sapTimeAt(frame.Fields[timeIdx], i, loc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert time column: %w", err)
+		}
+		if t == nil {
+			skipped++
+			continue
+		}
+		var end *time.Time
+		if timeEndIdx != -1 {
+			if end, err = sapTimeAt(frame.Fields[timeEndIdx], i, loc); err != nil {
+				return nil, fmt.Errorf("failed to convert timeend column: %w", err)
+			}
+		}
+		var text, title string
+		if textIdx != -1 {
+			text, _ = stringAt(frame.Fields[textIdx], i)
+		}
+		if titleIdx != -1 {
+			title, _ = stringAt(frame.Fields[titleIdx], i)
+		}
+
+		times = append(times, *t)
+		timeEnds = append(timeEnds, end)
+		texts = append(texts, text)
+		titles = append(titles, title)
+		tags = append(tags, annotationTags(tagFields, i))
+		for j, f := range extraFields {
+			extras[j].Append(f.At(i))
+		}
+	}
+
+	annotations := data.NewFrame(frame.Name,
+		data.NewField("time", nil, times),
+		data.NewField("timeEnd", nil, timeEnds),
+		data.NewField("title", nil, titles),
+		data.NewField("text", nil, texts),
+		data.NewField("tags", nil, tags),
+	)
+	annotations.Fields = append(annotations.Fields, extras...)
+	annotations.Meta = frame.Meta
+	if skipped > 0 {
+		annotations.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("%d rows without time were skipped", skipped),
+		})
+	}
+	return annotations, nil
+}
+
+// annotationTags merges the tags of all tag columns of a row into a comma separated list
+// without duplicates.
+func annotationTags(fields []*data.Field, row int) string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, f := range fields {
+		s, ok := stringAt(f, row)
+		if !ok {
+			continue
+		}
+		for _, tag := range strings.Split(s, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return strings.Join(tags, ",")
+}
@@ -0,0 +1,103 @@
+package sqleng
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestParseSAPTime(t *testing.T) {
+	berlin := ParseLocation("Europe/Berlin")
+	tests := []struct {
+		in   string
+		loc  *time.Location
+		want time.Time
+		null bool
+	}{
+		{in: "20240131", loc: time.UTC, want: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{in: "20240131083000", loc: berlin, want: time.Date(2024, 1, 31, 7, 30, 0, 0, time.UTC)},
+		{in: "1706689800", loc: time.UTC, want: time.Date(2024, 1, 31, 8, 30, 0, 0, time.UTC)},
+		{in: "1706689800000", loc: time.UTC, want: time.Date(2024, 1, 31, 8, 30, 0, 0, time.UTC)},
+		{in: "2024-01-31 08:30:00", loc: time.UTC, want: time.Date(2024, 1, 31, 8, 30, 0, 0, time.UTC)},
+		{in: "00000000", loc: time.UTC, null: true},
+		{in: " ", loc: time.UTC, null: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSAPTime(tt.in, tt.loc)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.in, err)
+		}
+		if tt.null {
+			if got != nil {
+				t.Fatalf("%q: got %v, want nil", tt.in, got)
+			}
+			continue
+		}
+		if got == nil || !got.Equal(tt.want) {
+			t.Fatalf("%q: got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if _, err := parseSAPTime("yesterday", time.UTC); err == nil {
+		t.Fatal("expected an error for an invalid date")
+	}
+	if loc := ParseLocation("+02:00"); time.Date(2024, 1, 1, 0, 0, 0, 0, loc).Unix() != time.Date(2023, 12, 31, 22, 0, 0, 0, time.UTC).Unix() {
+		t.Fatalf("unexpected offset location %v", loc)
+	}
+}
+
+func TestConvertToAnnotationsFrame(t *testing.T) {
+	frame := data.NewFrame("",
+		data.NewField("TIME", nil, []*string{strPtr("20240131083000"), strPtr("00000000"), strPtr("20240201")}),
+		data.NewField("TIMEEND", nil, []*int64{int64Ptr(20240131120000), nil, int64Ptr(1706745600)}),
+		data.NewField("TITLE", nil, []string{"Transport K900123", "", "Plant shutdown"}),
+		data.NewField("TEXT", nil, []string{"imported", "", "maintenance"}),
+		data.NewField("TAGS", nil, []string{"transport, PRD", "", "plant"}),
+		data.NewField("TAG_SYSTEM", nil, []*string{strPtr("PRD"), nil, strPtr("S4H")}),
+		data.NewField("ID", nil, []int64{1, 2, 3}),
+	)
+	annotations, err := convertToAnnotationsFrame(frame, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"time", "timeEnd", "title", "text", "tags", "ID"}
+	if len(annotations.Fields) != len(want) {
+		t.Fatalf("got %d fields, want %v", len(annotations.Fields), want)
+	}
+	for i, name := range want {
+		if annotations.Fields[i].Name != name {
+			t.Fatalf("field %d is %q, want %q", i, annotations.Fields[i].Name, name)
+		}
+	}
+	if rows := annotations.Rows(); rows != 2 {
+		t.Fatalf("got %d rows, want 2", rows)
+	}
+	if len(annotations.Meta.Notices) != 1 {
+		t.Fatalf("expected a notice for the skipped row, got %v", annotations.Meta.Notices)
+	}
+
+	if got := annotations.Fields[0].At(0).(time.Time); !got.Equal(time.Date(2024, 1, 31, 8, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected time %v", got)
+	}
+	if got := annotations.Fields[1].At(0).(*time.Time); !got.Equal(time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected timeEnd %v", got)
+	}
+	if got := annotations.Fields[1].At(1).(*time.Time); !got.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected epoch timeEnd %v", got)
+	}
+	if got := annotations.Fields[4].At(0).(string); got != "transport,PRD" {
+		t.Fatalf("unexpected tags %q", got)
+	}
+	if got := annotations.Fields[4].At(1).(string); got != "plant,S4H" {
+		t.Fatalf("unexpected tags %q", got)
+	}
+	if got := annotations.Fields[5].At(1).(int64); got != 3 {
+		t.Fatalf("unexpected id %d", got)
+	}
+
+	if _, err := convertToAnnotationsFrame(data.NewFrame("", data.NewField("TEXT", nil, []string{"a"})), time.UTC); err == nil {
+		t.Fatal("expected an error without time column")
+	}
+}
+
+func int64Ptr(i int64) *int64 { return &i }
@@ -0,0 +1,382 @@
+package sqleng
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// builderAggregates are the aggregate functions of the visual query builder.
+var builderAggregates = map[string]bool{
+	"SUM": true, "AVG": true, "MIN": true, "MAX": true, "COUNT": true, "VARIANCE": true, "STDDEV": true,
+}
+
+// builderMacros are the time group macros of the visual query builder. The alias variants
+// are compiled to their base macro with the alias time, so the expression can be grouped by.
+var builderMacros = map[string]string{
+	"$__timeGroup":           "$__timeGroup",
+	"$__timeGroupAlias":      "$__timeGroup",
+	"$__unixEpochGroup":      "$__unixEpochGroup",
+	"$__unixEpochGroupAlias": "$__unixEpochGroup",
+}
+
+// builderTimeFilter is the condition operator filtering a column by the time range.
+const builderTimeFilter = "$__timeFilter"
+
+// macroExpander interpolates the macros of a fragment generated by a query compiler. Only
+// the generated fragments are interpolated, so macros in the values of a query stay text.
+type macroExpander func(fragment string) (string, error)
+
+// builderOperators are the condition operators of the visual query builder.
+var builderOperators = map[string]string{
+	"=": "=", "<>": "<>", "!=": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">=",
+	"LIKE": "LIKE", "NOT LIKE": "NOT LIKE", "IN": "IN", "NOT IN": "NOT IN", "BETWEEN": "BETWEEN",
+	"IS NULL": "IS NULL", "IS NOT NULL": "IS NOT NULL",
+}
+
+// builderOperator returns the SQL operator of a condition operator.
+func builderOperator(op string) (string, bool) {
+	if op == builderTimeFilter {
+		return op, true
+	}
+	res, ok := builderOperators[strings.ToUpper(strings.TrimSpace(op))]
+	return res, ok
+}
+
+// BuilderQuery is a table query of the visual query builder, compiled to SQL by the backend.
+type BuilderQuery struct {
+	Schema  string             `json:"schema,omitempty"`
+	Table   string             `json:"table"`
+	Columns []BuilderColumn    `json:"columns"`
+	Where   []BuilderCondition `json:"where,omitempty"`
+	// Conjunction combines the conditions, AND if empty.
+	Conjunction string         `json:"conjunction,omitempty"`
+	GroupBy     []string       `json:"groupBy,omitempty"`
+	OrderBy     []BuilderOrder `json:"orderBy,omitempty"`
+	Limit       int64          `json:"limit,omitempty"`
+}
+
+// BuilderColumn is a selected column, optionally aggregated or passed to a time macro.
+// Parameters are the further macro arguments, the interval and the fill mode.
+type BuilderColumn struct {
+	Name       string   `json:"name"`
+	Function   string   `json:"function,omitempty"`
+	Parameters []string `json:"parameters,omitempty"`
+	Alias      string   `json:"alias,omitempty"`
+}
+
+// BuilderCondition is a condition of the where clause.
+type BuilderCondition struct {
+	Column   string   `json:"column"`
+	Operator string   `json:"operator"`
+	Value    string   `json:"value,omitempty"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// BuilderOrder sorts by a column or the alias of a selected column.
+type BuilderOrder struct {
+	Column     string `json:"column"`
+	Descending bool   `json:"descending,omitempty"`
+}
+
+func (q BuilderQuery) validate() error {
+	if q.Table == "" {
+		return errors.New("builder query without table")
+	}
+	if len(q.Columns) == 0 {
+		return errors.New("builder query without columns")
+	}
+	for _, c := range q.Columns {
+		if c.Function == "" || c.Function == "$__time" || builderAggregates[strings.ToUpper(c.Function)] {
+			continue
+		}
+		if _, ok := builderMacros[c.Function]; !ok {
+			return fmt.Errorf("unsupported builder function %q", c.Function)
+		}
+		for i, p := range c.Parameters {
+			pattern := intervalArgPattern
+			if i > 0 {
+				pattern = fillArgPattern
+			}
+			if !pattern.MatchString(strings.Trim(p, `'"`)) {
+				return fmt.Errorf("invalid argument %q of builder function %s", p, c.Function)
+			}
+		}
+	}
+	for _, w := range q.Where {
+		if _, ok := builderOperator(w.Operator); !ok {
+			return fmt.Errorf("unsupported builder operator %q", w.Operator)
+		}
+	}
+	switch strings.ToUpper(q.Conjunction) {
+	case "", "AND", "OR":
+	default:
+		return fmt.Errorf("unsupported builder conjunction %q", q.Conjunction)
+	}
+	if q.Limit < 0 {
+		return errors.New("builder query limit must not be negative")
+	}
+	return nil
+}
+
+// builderSelect is a compiled select list entry.
+type builderSelect struct {
+	expr  string
+	alias string
+}
+
+func (s builderSelect) String() string {
+	if s.alias == "" {
+		return s.expr
+	}
+	return s.expr + " AS " + quoteIdentifier(s.alias)
+}
+
+// compileBuilderColumn compiles a selected column. Only columns of the table are accepted.
+func compileBuilderColumn(c BuilderColumn, columns []adhocColumn, expand macroExpander) (builderSelect, error) {
+	fn := c.Function
+	if c.Name == "*" {
+		switch strings.ToUpper(fn) {
+		case "":
+			return builderSelect{expr: "*"}, nil
+		case "COUNT":
+			return builderSelect{expr: "COUNT(*)", alias: c.Alias}, nil
+		}
+		return builderSelect{}, fmt.Errorf("* can only be counted")
+	}
+	col, err := findAdhocColumn(columns, c.Name)
+	if err != nil {
+		return builderSelect{}, fmt.Errorf("unknown column %q", c.Name)
+	}
+	ident := quoteIdentifier(col.name)
+	switch {
+	case fn == "":
+		return builderSelect{expr: ident, alias: c.Alias}, nil
+	case fn == "$__time":
+		return builderSelect{expr: ident, alias: "time"}, nil
+	case builderAggregates[strings.ToUpper(fn)]:
+		return builderSelect{expr: fmt.Sprintf("%s(%s)", strings.ToUpper(fn), ident), alias: c.Alias}, nil
+	}
+	args := append([]string{ident}, c.Parameters...)
+	if len(args) > 1 {
+		args[1] = quoteLiteral(strings.Trim(args[1], `'"`))
+	}
+	expr, err := expand(fmt.Sprintf("%s(%s)", builderMacros[fn], strings.Join(args, ", ")))
+	if err != nil {
+		return builderSelect{}, err
+	}
+	s := builderSelect{expr: expr, alias: c.Alias}
+	if builderMacros[fn] != fn {
+		s.alias = "time"
+	}
+	return s, nil
+}
+
+// compileBuilderCondition compiles a where condition with literals of the column type.
+func compileBuilderCondition(w BuilderCondition, columns []adhocColumn, loc *time.Location, expand macroExpander) (string, error) {
+	col, err := findAdhocColumn(columns, w.Column)
+	if err != nil {
+		return "", fmt.Errorf("unknown column %q", w.Column)
+	}
+	ident := quoteIdentifier(col.name)
+	op, _ := builderOperator(w.Operator)
+	literals := func(values []string) ([]string, error) {
+		res := make([]string, len(values))
+		for i, v := range values {
+			l, err := typedLiteral(col.typeName, v, loc)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = l
+		}
+		return res, nil
+	}
+	switch op {
+	case builderTimeFilter:
+		return expand(fmt.Sprintf("%s(%s)", builderTimeFilter, ident))
+	case "IS NULL", "IS NOT NULL":
+		return ident + " " + op, nil
+	case "LIKE", "NOT LIKE":
+		return fmt.Sprintf("%s %s %s", ident, op, quoteString(w.Value)), nil
+	case "IN", "NOT IN":
+		values := w.Values
+		if len(values) == 0 {
+			values = strings.Split(w.Value, ",")
+		}
+		l, err := literals(values)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s (%s)", ident, op, strings.Join(l, ", ")), nil
+	case "BETWEEN":
+		if len(w.Values) != 2 {
+			return "", fmt.Errorf("BETWEEN needs two values")
+		}
+		l, err := literals(w.Values)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s BETWEEN %s AND %s", ident, l[0], l[1]), nil
+	}
+	l, err := literals([]string{w.Value})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", ident, op, l[0]), nil
+}
+
+// compileBuilderQuery compiles a builder query against the columns of its table. Group by
+// and order by entries may refer to the alias of a selected column.
+func compileBuilderQuery(q BuilderQuery, schema string, columns []adhocColumn, loc *time.Location, expand macroExpander) (string, error) {
+	selects := make([]builderSelect, len(q.Columns))
+	for i, c := range q.Columns {
+		s, err := compileBuilderColumn(c, columns, expand)
+		if err != nil {
+			return "", err
+		}
+		selects[i] = s
+	}
+	byAlias := func(name string) (builderSelect, bool) {
+		for _, s := range selects {
+			if s.alias != "" && s.alias == name {
+				return s, true
+			}
+		}
+		return builderSelect{}, false
+	}
+
+	conditions := make([]string, len(q.Where))
+	for i, w := range q.Where {
+		cond, err := compileBuilderCondition(w, columns, loc, expand)
+		if err != nil {
+			return "", err
+		}
+		conditions[i] = cond
+	}
+
+	groups := make([]string, len(q.GroupBy))
+	for i, g := range q.GroupBy {
+		if s, ok := byAlias(g); ok {
+			groups[i] = s.expr
+			continue
+		}
+		col, err := findAdhocColumn(columns, g)
+		if err != nil {
+			return "", fmt.Errorf("unknown group by column %q", g)
+		}
+		groups[i] = quoteIdentifier(col.name)
+	}
+
+	orders := make([]string, len(q.OrderBy))
+	for i, o := range q.OrderBy {
+		var expr string
+		if s, ok := byAlias(o.Column); ok {
+			expr = quoteIdentifier(s.alias)
+		} else {
+			col, err := findAdhocColumn(columns, o.Column)
+			if err != nil {
+				return "", fmt.Errorf("unknown order by column %q", o.Column)
+			}
+			expr = quoteIdentifier(col.name)
+		}
+		if o.Descending {
+			expr += " DESC"
+		}
+		orders[i] = expr
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	for i, s := range selects {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(s.String())
+	}
+	sb.WriteString("\nFROM ")
+	if schema != "" {
+		sb.WriteString(quoteIdentifier(schema) + ".")
+	}
+	sb.WriteString(quoteIdentifier(q.Table))
+	if len(conditions) > 0 {
+		conjunction := " AND "
+		if strings.EqualFold(q.Conjunction, "OR") {
+			conjunction = " OR "
+		}
+		sb.WriteString("\nWHERE ")
+		sb.WriteString(strings.Join(conditions, conjunction))
+	}
+	if len(groups) > 0 {
+		sb.WriteString("\nGROUP BY ")
+		sb.WriteString(strings.Join(groups, ", "))
+	}
+	if len(orders) > 0 {
+		sb.WriteString("\nORDER BY ")
+		sb.WriteString(strings.Join(orders, ", "))
+	}
+	if q.Limit > 0 {
+		fmt.Fprintf(&sb, "\nLIMIT %d", q.Limit)
+	}
+	return sb.String(), nil
+}
+
+// tableColumns reads the columns of a table or view. They are cached like the catalog
+// listings.
+func (e *DataSourceHandler) tableColumns(ctx context.Context, schema, table string) ([]CatalogColumn, error) {
+	key := "table-columns?" + url.Values{"schema": {schema}, "table": {table}}.Encode()
+	if body, ok := e.metadataCache.get(key); ok {
+		var columns []CatalogColumn
+		if err := json.Unmarshal(body, &columns); err == nil {
+			return columns, nil
+		}
+	}
+	query, args := catalogObjects["columns"].query(catalogRequest{schema: schema, table: table, limit: maxResourceLimit})
+	columns := []CatalogColumn{}
+	err := e.scanCatalog(ctx, len(catalogObjects["columns"].columns), func(v []any) {
+		columns = append(columns, CatalogColumn{Name: catalogString(v[0]), Type: catalogString(v[1])})
+	}, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %q not found", table)
+	}
+	if body, err := json.Marshal(columns); err == nil {
+		e.metadataCache.set(key, body)
+	}
+	return columns, nil
+}
+
+// referenceColumns returns a lookup of the columns of the tables a statement references.
+// Unqualified names are looked up in the default schema of the data source.
+func (e *DataSourceHandler) referenceColumns(ctx context.Context) func(tableReference) ([]CatalogColumn, error) {
+	return func(ref tableReference) ([]CatalogColumn, error) {
+		schema := ref.schema
+		if schema == "" {
+			schema = e.dsInfo.JsonData.DefaultSchema
+		}
+		return e.tableColumns(ctx, schema, ref.name)
+	}
+}
+
+// compileBuilder compiles a builder query with the columns of its table. The schema
+// defaults to the default schema of the data source.
+func (e *DataSourceHandler) compileBuilder(ctx context.Context, q BuilderQuery, expand macroExpander) (string, error) {
+	schema := q.Schema
+	if schema == "" {
+		schema = e.dsInfo.JsonData.DefaultSchema
+	}
+	tableColumns, err := e.tableColumns(ctx, schema, q.Table)
+	if err != nil {
+		return "", err
+	}
+	columns := make([]adhocColumn, len(tableColumns))
+	for i, c := range tableColumns {
+		columns[i] = adhocColumn{name: c.Name, typeName: c.Type}
+	}
+	return compileBuilderQuery(q, schema, columns, e.location(), expand)
+}
@@ -0,0 +1,94 @@
+package sqleng
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// keepMacros leaves the macros of the compiled fragments for the expected SQL.
+func keepMacros(fragment string) (string, error) { return fragment, nil }
+
+func TestCompileBuilderQuery(t *testing.T) {
+	columns := []adhocColumn{
+		{name: "CREATED_AT", typeName: "TIMESTAMP"},
+		{name: "PLANT", typeName: "NVARCHAR"},
+		{name: "QTY", typeName: "DECIMAL"},
+	}
+	q := BuilderQuery{
+		Table: "ORDERS",
+		Columns: []BuilderColumn{
+			{Name: "created_at", Function: "$__timeGroupAlias", Parameters: []string{"'1h'", "0"}},
+			{Name: "PLANT"},
+			{Name: "QTY", Function: "sum", Alias: "Total"},
+		},
+		Where: []BuilderCondition{
+			{Column: "CREATED_AT", Operator: "$__timeFilter"},
+			{Column: "PLANT", Operator: "in", Values: []string{"1000", "O'Neil"}},
+			{Column: "QTY", Operator: ">=", Value: "5"},
+		},
+		GroupBy: []string{"time", "PLANT"},
+		OrderBy: []BuilderOrder{{Column: "time"}, {Column: "Total", Descending: true}},
+		Limit:   50,
+	}
+	if err := q.validate(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := compileBuilderQuery(q, "APP", columns, time.UTC, keepMacros)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT $__timeGroup("CREATED_AT", '1h', 0) AS "time", "PLANT", SUM("QTY") AS "Total"` +
+		"\nFROM \"APP\".\"ORDERS\"" +
+		"\nWHERE $__timeFilter(\"CREATED_AT\") AND \"PLANT\" IN (N'1000', N'O''Neil') AND \"QTY\" >= 5" +
+		"\nGROUP BY $__timeGroup(\"CREATED_AT\", '1h', 0), \"PLANT\"" +
+		"\nORDER BY \"time\", \"Total\" DESC" +
+		"\nLIMIT 50"
+	if got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+
+	for _, bad := range []BuilderQuery{
+		{Table: "ORDERS", Columns: []BuilderColumn{{Name: "MISSING"}}},
+		{Table: "ORDERS", Columns: []BuilderColumn{{Name: "QTY"}}, Where: []BuilderCondition{{Column: "QTY", Operator: "=", Value: "1 OR 1=1"}}},
+		{Table: "ORDERS", Columns: []BuilderColumn{{Name: "QTY"}}, OrderBy: []BuilderOrder{{Column: "QTY; DROP TABLE ORDERS"}}},
+		{Table: "ORDERS", Columns: []BuilderColumn{{Name: "*", Function: "SUM"}}},
+	} {
+		if _, err := compileBuilderQuery(bad, "", columns, time.UTC, keepMacros); err == nil {
+			t.Fatalf("expected an error for %+v", bad)
+		}
+	}
+	for _, bad := range []BuilderQuery{
+		{Table: "ORDERS", Columns: []BuilderColumn{{Name: "QTY", Function: "UPPER"}}},
+		{Table: "ORDERS", Columns: []BuilderColumn{{Name: "CREATED_AT", Function: "$__timeGroup", Parameters: []string{"1h) --"}}}},
+		{Table: "ORDERS", Columns: []BuilderColumn{{Name: "QTY"}}, Where: []BuilderCondition{{Column: "QTY", Operator: "; DELETE"}}},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Fatalf("expected a validation error for %+v", bad)
+		}
+	}
+}
+
+func TestCompileBuilderConditionMacroValue(t *testing.T) {
+	columns := []adhocColumn{{name: "A", typeName: "NVARCHAR"}, {name: "TS", typeName: "TIMESTAMP"}}
+	// the expander rewrites macros like the macro engine, also inside of literals
+	expand := func(fragment string) (string, error) {
+		return strings.NewReplacer("$__timeFilter", "TIME_FILTER", "$__in(x, '1')", "x IN (N'1')", "$__timeFrom()", "NOW()").Replace(fragment), nil
+	}
+	for _, c := range []struct {
+		cond BuilderCondition
+		want string
+	}{
+		{BuilderCondition{Column: "A", Operator: "LIKE", Value: "$__in(x, '1')"}, `"A" LIKE N'$__in(x, ''1'')'`},
+		{BuilderCondition{Column: "A", Operator: "=", Value: "$__timeFrom()"}, `"A" = N'$__timeFrom()'`},
+		{BuilderCondition{Column: "TS", Operator: "$__timeFilter"}, `TIME_FILTER("TS")`},
+	} {
+		got, err := compileBuilderCondition(c.cond, columns, time.UTC, expand)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Fatalf("got %s, want %s", got, c.want)
+		}
+	}
+}
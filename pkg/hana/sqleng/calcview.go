@@ -0,0 +1,203 @@
+package sqleng
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// repositorySchema holds the activated repository calculation views, which are named
+// "package/VIEW". Views deployed to HDI containers live in the container schema.
+const repositorySchema = "_SYS_BIC"
+
+// The BIMC tables hold the metadata of the calculation views. For repository views the
+// catalog is the package, for HDI views the container schema.
+const (
+	calcViewParametersQuery = `SELECT VARIABLE_NAME, PLACEHOLDER_NAME, DESCRIPTION, COLUMN_NAME, COLUMN_SQL_TYPE,` +
+		` MANDATORY, DEFAULT_VALUE, SELECTION_TYPE, MULTILINE FROM _SYS_BI.BIMC_VARIABLE` +
+		` WHERE CATALOG_NAME = ? AND CUBE_NAME = ? ORDER BY "ORDER", VARIABLE_NAME`
+	calcViewValuesQuery = `SELECT VARIABLE_NAME, VALUE_KEY, VALUE_DESCRIPTION FROM _SYS_BI.BIMC_VARIABLE_VALUE` +
+		` WHERE CATALOG_NAME = ? AND CUBE_NAME = ? ORDER BY VARIABLE_NAME, VALUE_KEY`
+)
+
+// CalcViewValue is an entry of the value help of a parameter.
+type CalcViewValue struct {
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+}
+
+// CalcViewParameter is an input parameter or variable of a calculation view.
+type CalcViewParameter struct {
+	Name        string `json:"name"`
+	Placeholder string `json:"placeholder"`
+	Description string `json:"description,omitempty"`
+	// Column is the filtered column of a variable, empty for input parameters.
+	Column        string          `json:"column,omitempty"`
+	Type          string          `json:"type,omitempty"`
+	Mandatory     bool            `json:"mandatory"`
+	Default       string          `json:"default,omitempty"`
+	SelectionType string          `json:"selectionType,omitempty"`
+	Multiple      bool            `json:"multiple"`
+	Values        []CalcViewValue `json:"values,omitempty"`
+}
+
+// calcViewCatalog returns the BIMC catalog and cube name of a calculation view.
+func calcViewCatalog(schema, view string) (string, string, error) {
+	if view == "" {
+		return "", "", fmt.Errorf("the view parameter is required")
+	}
+	if schema == "" || schema == repositorySchema {
+		i := strings.LastIndexByte(view, '/')
+		if i <= 0 || i == len(view)-1 {
+			return "", "", fmt.Errorf("repository view %q is not of the form package/VIEW", view)
+		}
+		return view[:i], view[i+1:], nil
+	}
+	return schema, view, nil
+}
+
+// placeholderClause renders the PLACEHOLDER clause passing the values to the view. The
+// parameters are sorted to keep the statement stable for the plan cache.
+func placeholderClause(values map[string]string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		placeholder := name
+		if !strings.HasPrefix(placeholder, "$$") {
+			placeholder = "$$" + placeholder + "$$"
+		}
+		parts[i] = fmt.Sprintf("'PLACEHOLDER' = (%s, %s)", quoteLiteral(placeholder), quoteLiteral(values[name]))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// quoteLiteral returns a HANA string literal. Placeholder names and values are not
+// unicode literals.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// catalogString converts a scanned catalog value to a string.
+func catalogString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// catalogBool converts a scanned catalog flag, stored as number or string, to a bool.
+func catalogBool(v any) bool {
+	s := strings.ToUpper(catalogString(v))
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return s == "X" || s == "Y"
+}
+
+// calcViewParametersHandler lists the input parameters and variables of a calculation
+// view together with their value help.
+func (e *DataSourceHandler) calcViewParametersHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeResourceError(rw, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	values := r.URL.Query()
+	catalog, cube, err := calcViewCatalog(values.Get("schema"), values.Get("view"))
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	key := "calculation-views/parameters?" + values.Encode()
+	if body, ok := e.metadataCache.get(key); ok {
+		writeResourceJSON(rw, body)
+		return
+	}
+	params, err := e.calcViewParameters(r.Context(), catalog, cube)
+	if err != nil {
+		e.log.Error("Calculation view parameter query failed", "error", err)
+		writeResourceError(rw, http.StatusInternalServerError, e.TransformQueryError(e.log, err))
+		return
+	}
+	body, err := json.Marshal(map[string]any{"parameters": params})
+	if err != nil {
+		writeResourceError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	e.metadataCache.set(key, body)
+	writeResourceJSON(rw, body)
+}
+
+// calcViewParameters reads the parameters of a calculation view from the BIMC tables.
+func (e *DataSourceHandler) calcViewParameters(ctx context.Context, catalog, cube string) ([]CalcViewParameter, error) {
+	params := []CalcViewParameter{}
+	byName := map[string]int{}
+	err := e.scanCatalog(ctx, 9, func(v []any) {
+		p := CalcViewParameter{
+			Name:          catalogString(v[0]),
+			Placeholder:   catalogString(v[1]),
+			Description:   catalogString(v[2]),
+			Column:        catalogString(v[3]),
+			Type:          catalogString(v[4]),
+			Mandatory:     catalogBool(v[5]),
+			Default:       catalogString(v[6]),
+			SelectionType: catalogString(v[7]),
+			Multiple:      catalogBool(v[8]),
+		}
+		if p.Placeholder == "" {
+			p.Placeholder = "$$" + p.Name + "$$"
+		}
+		byName[p.Name] = len(params)
+		params = append(params, p)
+	}, calcViewParametersQuery, catalog, cube)
+	if err != nil {
+		return nil, err
+	}
+	err = e.scanCatalog(ctx, 3, func(v []any) {
+		if i, ok := byName[catalogString(v[0])]; ok {
+			params[i].Values = append(params[i].Values, CalcViewValue{Value: catalogString(v[1]), Description: catalogString(v[2])})
+		}
+	}, calcViewValuesQuery, catalog, cube)
+	return params, err
+}
+
+// scanCatalog runs a catalog query and passes the values of every row to fn.
+func (e *DataSourceHandler) scanCatalog(ctx context.Context, columns int, fn func([]any), query string, args ...any) error {
+	rows, err := e.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			e.log.Warn("Failed to close rows", "err", err)
+		}
+	}()
+	values := make([]any, columns)
+	dest := make([]any, columns)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		fn(values)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,46 @@
+package sqleng
+
+import "testing"
+
+func TestCalcViewCatalog(t *testing.T) {
+	catalog, cube, err := calcViewCatalog("", "sales.reporting/CV_REVENUE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if catalog != "sales.reporting" || cube != "CV_REVENUE" {
+		t.Fatalf("got %q %q", catalog, cube)
+	}
+
+	catalog, cube, err = calcViewCatalog("SALES_HDI", "sales.db::CV_REVENUE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if catalog != "SALES_HDI" || cube != "sales.db::CV_REVENUE" {
+		t.Fatalf("got %q %q", catalog, cube)
+	}
+
+	for _, view := range []string{"", "CV_REVENUE", "sales/"} {
+		if _, _, err := calcViewCatalog(repositorySchema, view); err == nil {
+			t.Fatalf("expected an error for %q", view)
+		}
+	}
+}
+
+func TestPlaceholderClause(t *testing.T) {
+	got := placeholderClause(map[string]string{"P_YEAR": "2024", "$$P_CURRENCY$$": "EUR'"})
+	want := `('PLACEHOLDER' = ('$$P_CURRENCY$$', 'EUR'''), 'PLACEHOLDER' = ('$$P_YEAR$$', '2024'))`
+	if got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+	if got := placeholderClause(nil); got != "" {
+		t.Fatalf("expected no clause, got %q", got)
+	}
+}
+
+func TestCatalogBool(t *testing.T) {
+	for v, want := range map[any]bool{int64(1): true, int64(0): false, "TRUE": true, "X": true, "": false, nil: false} {
+		if got := catalogBool(v); got != want {
+			t.Fatalf("%v: got %v", v, got)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package sqleng
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// DecimalMode selects how DECIMAL columns are represented in data frames.
+type DecimalMode string
+
+const (
+	// DecimalModeFloat64 converts every decimal to float64 (default).
+	DecimalModeFloat64 DecimalMode = "float64"
+	// DecimalModeInt64 converts decimals with scale 0 to int64 and all others to float64.
+	DecimalModeInt64 DecimalMode = "int64"
+	// DecimalModeScaled converts decimals to int64 multiplied by 10^scale, with the scale as field hint.
+	DecimalModeScaled DecimalMode = "scaled"
+	// DecimalModeString converts decimals to their exact string representation.
+	DecimalModeString DecimalMode = "string"
+)
+
+// ParseDecimalMode validates a decimal mode. An empty string selects the default mode.
+func ParseDecimalMode(s string) (DecimalMode, error) {
+	switch m := DecimalMode(s); m {
+	case "":
+		return DecimalModeFloat64, nil
+	case DecimalModeFloat64, DecimalModeInt64, DecimalModeScaled, DecimalModeString:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown decimal mode %q", s)
+	}
+}
+
+// ConverterOptions carries the per-query context of the result converters.
+// A new value is created for every query, so converters may update it while rows are scanned.
+type ConverterOptions struct {
+	DecimalMode DecimalMode
+	ColumnTypes []*sql.ColumnType
+	// FieldConfigs are applied to the fields with the matching name once the frame is built.
+	FieldConfigs map[string]*data.FieldConfig
+	// PrecisionLoss counts the values which could not be represented exactly.
+	PrecisionLoss int64
+	// FrameTransforms reshape the frame once all rows are converted, e.g. to split a column into several fields.
+	FrameTransforms []func(frame *data.Frame) error
+}
+
+// apply runs the frame transforms and sets the collected field configs and precision notices on the frame.
+func (o *ConverterOptions) apply(frame *data.Frame) error {
+	for _, transform := range o.FrameTransforms {
+		if err := transform(frame); err != nil {
+			return err
+		}
+	}
+	for _, field := range frame.Fields {
+		if cfg, ok := o.FieldConfigs[field.Name]; ok {
+			field.SetConfig(cfg)
+		}
+	}
+	if o.PrecisionLoss > 0 {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text: fmt.Sprintf("%d decimal values lost precision in %q decimal mode, use the %q mode for exact values",
+				o.PrecisionLoss, o.DecimalMode, DecimalModeString),
+		})
+	}
+	return nil
+}
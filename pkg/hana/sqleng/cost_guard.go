@@ -0,0 +1,103 @@
+package sqleng
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxReportedOperators limits the operators listed by a cost guard rejection.
+const maxReportedOperators = 5
+
+// costGuardEnabled reports whether queries of the origin are checked by the cost guard.
+// The dashboard of a query is taken from a request header, which any client of the query
+// API can set, so dashboard exemptions are a convenience and not enforced like user ones.
+func (d JsonData) costGuardEnabled(origin queryOrigin) bool {
+	if d.MaxEstimatedCost <= 0 && d.MaxEstimatedRows <= 0 {
+		return false
+	}
+	if origin.dashboardUID != "" && slices.Contains(d.CostGuardExemptDashboards, origin.dashboardUID) {
+		return false
+	}
+	if origin.user != "" && slices.ContainsFunc(d.CostGuardExemptUsers, func(u string) bool { return strings.EqualFold(u, origin.user) }) {
+		return false
+	}
+	return true
+}
+
+// costViolation returns an error if the estimates of a plan exceed the limits. The cost of
+// the statement is the subtree cost of its root operators, the rows are checked per
+// operator, so a full scan is caught even if the result is aggregated.
+func costViolation(plan []planOperator, maxCost, maxRows float64) error {
+	var cost, rows float64
+	var expensive []planOperator
+	for _, op := range plan {
+		if !op.parentID.Valid && op.cost.Valid {
+			cost += op.cost.Float64
+		}
+		if op.outputSize.Valid && op.outputSize.Float64 > rows {
+			rows = op.outputSize.Float64
+		}
+		if (maxCost > 0 && op.cost.Valid && op.cost.Float64 > maxCost) ||
+			(maxRows > 0 && op.outputSize.Valid && op.outputSize.Float64 > maxRows) {
+			expensive = append(expensive, op)
+		}
+	}
+
+	var reasons []string
+	if maxCost > 0 && cost > maxCost {
+		reasons = append(reasons, fmt.Sprintf("estimated cost %s exceeds the limit of %s", formatEstimate(cost), formatEstimate(maxCost)))
+	}
+	if maxRows > 0 && rows > maxRows {
+		reasons = append(reasons, fmt.Sprintf("estimated %s rows exceed the limit of %s", formatEstimate(rows), formatEstimate(maxRows)))
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(expensive, func(i, j int) bool { return expensive[i].cost.Float64 > expensive[j].cost.Float64 })
+	if len(expensive) > maxReportedOperators {
+		expensive = expensive[:maxReportedOperators]
+	}
+	ops := make([]string, len(expensive))
+	for i, op := range expensive {
+		name := op.name
+		if op.table != "" {
+			name += " " + op.schema + "." + op.table
+		}
+		ops[i] = fmt.Sprintf("%s (rows %s, cost %s)", name, formatEstimate(op.outputSize.Float64), formatEstimate(op.cost.Float64))
+	}
+	msg := strings.Join(reasons, ", ")
+	if len(ops) > 0 {
+		msg += "; expensive operators: " + strings.Join(ops, ", ")
+	}
+	return fmt.Errorf("%s. Narrow the time range or the filters of the query", msg)
+}
+
+func formatEstimate(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+// checkQueryCost explains a query and rejects it if its estimates exceed the limits of the
+// datasource. A query which cannot be explained is not rejected, HANA reports the error
+// when running it.
+func (e *DataSourceHandler) checkQueryCost(ctx context.Context, statement string, origin queryOrigin) error {
+	jd := e.dsInfo.JsonData
+	if !jd.costGuardEnabled(origin) {
+		return nil
+	}
+	logger := e.log.FromContext(ctx)
+	plan, err := e.explainPlan(ctx, statement)
+	if err != nil {
+		logger.Warn("Cost guard failed to explain the query", "err", err)
+		return nil
+	}
+	if err := costViolation(plan, jd.MaxEstimatedCost, jd.MaxEstimatedRows); err != nil {
+		logger.Info("Cost guard rejected a query", "dashboardUID", origin.dashboardUID, "user", origin.user, "reason", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package sqleng
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestCostViolation(t *testing.T) {
+	plan := []planOperator{
+		{id: 1, level: 1, name: "AGGREGATION", outputSize: sql.NullFloat64{Float64: 12, Valid: true}, cost: sql.NullFloat64{Float64: 5000, Valid: true}},
+		{id: 2, parentID: sql.NullInt64{Int64: 1, Valid: true}, level: 2, name: "COLUMN SEARCH", schema: "SAPHANADB", table: "ACDOCA",
+			outputSize: sql.NullFloat64{Float64: 1.2e9, Valid: true}, cost: sql.NullFloat64{Float64: 4900, Valid: true}},
+	}
+
+	if err := costViolation(plan, 10000, 2e9); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+
+	err := costViolation(plan, 0, 1e6)
+	if err == nil {
+		t.Fatal("expected the full scan to be rejected")
+	}
+	for _, want := range []string{"estimated 1.2e+09 rows exceed the limit of 1e+06", "COLUMN SEARCH SAPHANADB.ACDOCA (rows 1.2e+09, cost 4900)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q does not contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "AGGREGATION") {
+		t.Fatalf("cheap operator listed: %q", err)
+	}
+
+	err = costViolation(plan, 1000, 0)
+	if err == nil || !strings.Contains(err.Error(), "estimated cost 5000 exceeds the limit of 1000") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestCostGuardEnabled(t *testing.T) {
+	jd := JsonData{MaxEstimatedRows: 1e6, CostGuardExemptDashboards: []string{"finance"}, CostGuardExemptUsers: []string{"Admin"}}
+	if !jd.costGuardEnabled(queryOrigin{dashboardUID: "sales", user: "viewer"}) {
+		t.Fatal("expected the guard to be enabled")
+	}
+	if jd.costGuardEnabled(queryOrigin{dashboardUID: "finance", user: "viewer"}) {
+		t.Fatal("expected the dashboard to be exempt")
+	}
+	if jd.costGuardEnabled(queryOrigin{user: "admin"}) {
+		t.Fatal("expected the user to be exempt")
+	}
+	if (JsonData{}).costGuardEnabled(queryOrigin{}) {
+		t.Fatal("expected the guard to be disabled without limits")
+	}
+}
@@ -0,0 +1,168 @@
+package sqleng
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// fromAlertHeader is set by Grafana on the queries of alert and recording rules.
+const fromAlertHeader = "FromAlert"
+
+// isAlertRequest reports whether the request was sent by the alerting engine.
+func isAlertRequest(req *backend.QueryDataRequest) bool {
+	return req.Headers[fromAlertHeader] == "true" || req.GetHTTPHeader(fromAlertHeader) == "true"
+}
+
+// isValueField reports whether a field can be used as value of a numeric or time series frame.
+func isValueField(f *data.Field) bool {
+	t := f.Type().NonNullableType()
+	return t.Numeric() || t == data.FieldTypeBool
+}
+
+// dataplaneFrames converts a result into frames of the data plane contract. Results with a
+// time column become timeseries-multi frames, all others a numeric-long frame. String
+// columns are the dimensions, numeric and boolean columns the values.
+func dataplaneFrames(frame *data.Frame, qm *dataQueryModel) (data.Frames, error) {
+	timeIdx := qm.timeIndex
+	if timeIdx == -1 {
+		for i, f := range frame.Fields {
+			if t := f.Type(); t == data.FieldTypeTime || t == data.FieldTypeNullableTime {
+				timeIdx = i
+				break
+			}
+		}
+	}
+
+	if qm.fill != nil && timeIdx != -1 {
+		var err error
+		if frame, err = fillFrame(frame, timeIdx, qm.fill, qm.TimeRange); err != nil {
+			return nil, err
+		}
+		timeIdx = 0
+	}
+
+	var dims []*data.Field
+	var values []int
+	for i, f := range frame.Fields {
+		switch {
+		case i == timeIdx:
+		case isStringField(f):
+			dims = append(dims, f)
+		case isValueField(f):
+			values = append(values, i)
+		}
+	}
+	if len(values) == 0 {
+		return nil, errors.New("no numeric value column found; make sure your data includes at least one numeric column")
+	}
+	for _, i := range values {
+		var err error
+		if frame, err = convertSQLValueColumnToFloat(frame, i); err != nil {
+			return nil, err
+		}
+	}
+
+	var frames data.Frames
+	if timeIdx == -1 {
+		frames = data.Frames{numericLongFrame(frame, dims, values)}
+	} else {
+		frames = timeSeriesMultiFrames(frame, timeIdx, dims, values)
+	}
+	if len(frames) == 0 {
+		// an empty response still declares its type
+		frames = data.Frames{data.NewFrame(frame.Name)}
+		frames[0].Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, TypeVersion: data.FrameTypeVersion{0, 1}}
+	}
+	if frame.Meta != nil {
+		frames[0].Meta.ExecutedQueryString = frame.Meta.ExecutedQueryString
+		frames[0].Meta.Notices = append(frames[0].Meta.Notices, frame.Meta.Notices...)
+	}
+	return frames, nil
+}
+
+// numericLongFrame keeps the dimension and value columns of a result without time.
+func numericLongFrame(frame *data.Frame, dims []*data.Field, values []int) *data.Frame {
+	numeric := data.NewFrame(frame.Name, dims...)
+	for _, i := range values {
+		numeric.Fields = append(numeric.Fields, frame.Fields[i])
+	}
+	numeric.Meta = &data.FrameMeta{Type: data.FrameTypeNumericLong, TypeVersion: data.FrameTypeVersion{0, 1}}
+	return numeric
+}
+
+// timeSeries holds the rows of one label set of a long result.
+type timeSeries struct {
+	labels data.Labels
+	key    string
+	rows   []int
+}
+
+// groupTimeSeries groups the rows of a long result by the values of the dimension
+// columns. The series are sorted by their labels, so the output is stable across queries.
+func groupTimeSeries(frame *data.Frame, timeIdx int, dims []*data.Field) []*timeSeries {
+	groups := map[string]*timeSeries{}
+	var series []*timeSeries
+	for i := 0; i < frame.Rows(); i++ {
+		if _, ok := frame.Fields[timeIdx].ConcreteAt(i); !ok {
+			continue
+		}
+		labels := make(data.Labels, len(dims))
+		for _, d := range dims {
+			labels[d.Name], _ = stringAt(d, i)
+		}
+		key := labels.String()
+		s, ok := groups[key]
+		if !ok {
+			s = &timeSeries{labels: labels, key: key}
+			if len(labels) == 0 {
+				s.labels = nil
+			}
+			groups[key] = s
+			series = append(series, s)
+		}
+		s.rows = append(s.rows, i)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].key < series[j].key })
+
+	timeField := frame.Fields[timeIdx]
+	for _, s := range series {
+		sort.SliceStable(s.rows, func(i, j int) bool {
+			ti, _ := timeField.ConcreteAt(s.rows[i])
+			tj, _ := timeField.ConcreteAt(s.rows[j])
+			return ti.(time.Time).Before(tj.(time.Time))
+		})
+	}
+	return series
+}
+
+// timeSeriesMultiFrames returns one frame per value column and label set, each with its
+// own time field, as defined by the timeseries-multi format of the data plane contract.
+func timeSeriesMultiFrames(frame *data.Frame, timeIdx int, dims []*data.Field, values []int) data.Frames {
+	series := groupTimeSeries(frame, timeIdx, dims)
+	timeField := frame.Fields[timeIdx]
+	frames := make(data.Frames, 0, len(values)*len(series))
+	for _, vi := range values {
+		valueField := frame.Fields[vi]
+		for _, s := range series {
+			times := make([]time.Time, len(s.rows))
+			vals := make([]*float64, len(s.rows))
+			for j, row := range s.rows {
+				t, _ := timeField.ConcreteAt(row)
+				times[j] = t.(time.Time)
+				vals[j], _ = valueField.NullableFloatAt(row)
+			}
+			f := data.NewFrame(valueField.Name,
+				data.NewField(data.TimeSeriesTimeFieldName, nil, times),
+				data.NewField(valueField.Name, s.labels, vals),
+			)
+			f.Fields[1].Config = valueField.Config
+			f.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, TypeVersion: data.FrameTypeVersion{0, 1}}
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
@@ -0,0 +1,78 @@
+package sqleng
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestIsAlertRequest(t *testing.T) {
+	if isAlertRequest(&backend.QueryDataRequest{}) {
+		t.Fatal("request without header detected as alert")
+	}
+	if !isAlertRequest(&backend.QueryDataRequest{Headers: map[string]string{"FromAlert": "true"}}) {
+		t.Fatal("alert request not detected")
+	}
+}
+
+func TestDataplaneFramesTimeSeriesMulti(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	frame := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{t0.Add(time.Minute), t0, t0, t0.Add(2 * time.Minute)}),
+		data.NewField("HOST", nil, []string{"b", "b", "a", "b"}),
+		data.NewField("CPU", nil, []*int64{int64Ptr(2), int64Ptr(1), int64Ptr(5), nil}),
+	)
+	frame.Meta = &data.FrameMeta{ExecutedQueryString: "SELECT 1"}
+	frames, err := dataplaneFrames(frame, &dataQueryModel{timeIndex: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	if frames[0].Meta.ExecutedQueryString != "SELECT 1" {
+		t.Fatalf("executed query not kept: %+v", frames[0].Meta)
+	}
+	for i, host := range []string{"a", "b"} {
+		f := frames[i]
+		if f.Meta.Type != data.FrameTypeTimeSeriesMulti {
+			t.Fatalf("frame %d has type %q", i, f.Meta.Type)
+		}
+		if got := f.Fields[1].Labels["HOST"]; got != host {
+			t.Fatalf("frame %d has host %q, want %q", i, got, host)
+		}
+	}
+	b := frames[1]
+	if b.Rows() != 3 {
+		t.Fatalf("got %d rows, want 3", b.Rows())
+	}
+	if !b.Fields[0].At(0).(time.Time).Equal(t0) || *b.Fields[1].At(0).(*float64) != 1 {
+		t.Fatal("rows are not sorted by time")
+	}
+	if b.Fields[1].At(2).(*float64) != nil {
+		t.Fatal("null value not kept")
+	}
+}
+
+func TestDataplaneFramesNumericLong(t *testing.T) {
+	frame := data.NewFrame("",
+		data.NewField("PLANT", nil, []string{"1000", "2000"}),
+		data.NewField("STOCK", nil, []int64{10, 20}),
+		data.NewField("BLOCKED", nil, []bool{true, false}),
+	)
+	frames, err := dataplaneFrames(frame, &dataQueryModel{timeIndex: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 1 || frames[0].Meta.Type != data.FrameTypeNumericLong {
+		t.Fatalf("unexpected frames %v", frames)
+	}
+	if len(frames[0].Fields) != 3 || frames[0].Fields[1].Type() != data.FieldTypeNullableFloat64 {
+		t.Fatalf("unexpected fields %v", frames[0].Fields)
+	}
+	if _, err := dataplaneFrames(data.NewFrame("", data.NewField("PLANT", nil, []string{"1000"})), &dataQueryModel{timeIndex: -1}); err == nil {
+		t.Fatal("expected an error without value column")
+	}
+}
@@ -0,0 +1,208 @@
+package sqleng
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	explainPlanQuery = `SELECT OPERATOR_ID, PARENT_OPERATOR_ID, "LEVEL", OPERATOR_NAME, OPERATOR_DETAILS, SCHEMA_NAME,` +
+		` TABLE_NAME, EXECUTION_ENGINE, OUTPUT_SIZE, SUBTREE_COST FROM EXPLAIN_PLAN_TABLE` +
+		` WHERE STATEMENT_NAME = ? ORDER BY OPERATOR_ID`
+	explainCleanupQuery = `DELETE FROM EXPLAIN_PLAN_TABLE WHERE STATEMENT_NAME = ?`
+	planCacheQuery      = `SELECT PLAN_ID, EXECUTION_COUNT, AVG_EXECUTION_TIME FROM M_SQL_PLAN_CACHE` +
+		` WHERE STATEMENT_HASH = ? ORDER BY LAST_EXECUTION_TIMESTAMP DESC LIMIT 1`
+)
+
+// explainCounter makes the statement names of concurrent explains unique.
+var explainCounter atomic.Int64
+
+// planOperator is an operator of an explained plan.
+type planOperator struct {
+	id         int64
+	parentID   sql.NullInt64
+	level      int64
+	name       string
+	details    string
+	schema     string
+	table      string
+	engine     string
+	outputSize sql.NullFloat64
+	cost       sql.NullFloat64
+}
+
+// explainPlan explains a statement. The plan table is read on the connection which wrote
+// it and cleaned up afterwards.
+func (e *DataSourceHandler) explainPlan(ctx context.Context, statement string) ([]planOperator, error) {
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			e.log.Warn("Failed to close connection", "err", err)
+		}
+	}()
+
+	name := fmt.Sprintf("grafana_%d_%d", time.Now().UnixNano(), explainCounter.Add(1))
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("EXPLAIN PLAN SET STATEMENT_NAME = '%s' FOR %s", name, statement)); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, explainCleanupQuery, name); err != nil {
+			e.log.Warn("Failed to clean up explain plan", "err", err)
+		}
+	}()
+
+	rows, err := conn.QueryContext(ctx, explainPlanQuery, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			e.log.Warn("Failed to close rows", "err", err)
+		}
+	}()
+	var plan []planOperator
+	for rows.Next() {
+		var op planOperator
+		var details, schema, table, engine sql.NullString
+		if err := rows.Scan(&op.id, &op.parentID, &op.level, &op.name, &details, &schema, &table, &engine, &op.outputSize, &op.cost); err != nil {
+			return nil, err
+		}
+		op.details, op.schema, op.table, op.engine = details.String, schema.String, table.String, engine.String
+		plan = append(plan, op)
+	}
+	return plan, rows.Err()
+}
+
+// planFrame returns the plan as table, the operator names indented by their level.
+func planFrame(refID string, plan []planOperator) *data.Frame {
+	operator := make([]string, len(plan))
+	details := make([]string, len(plan))
+	table := make([]string, len(plan))
+	engine := make([]string, len(plan))
+	outputSize := make([]*float64, len(plan))
+	cost := make([]*float64, len(plan))
+	id := make([]int64, len(plan))
+	parentID := make([]*int64, len(plan))
+	for i, op := range plan {
+		level := op.level - 1
+		if level < 0 {
+			level = 0
+		}
+		operator[i] = strings.Repeat("  ", int(level)) + op.name
+		details[i] = op.details
+		if op.table != "" {
+			table[i] = op.schema + "." + op.table
+		}
+		engine[i] = op.engine
+		if op.outputSize.Valid {
+			outputSize[i] = &op.outputSize.Float64
+		}
+		if op.cost.Valid {
+			cost[i] = &op.cost.Float64
+		}
+		id[i] = op.id
+		if op.parentID.Valid {
+			parentID[i] = &op.parentID.Int64
+		}
+	}
+	frame := data.NewFrame("explain",
+		data.NewField("operator", nil, operator),
+		data.NewField("details", nil, details),
+		data.NewField("table", nil, table),
+		data.NewField("engine", nil, engine),
+		data.NewField("outputSize", nil, outputSize),
+		data.NewField("subtreeCost", nil, cost),
+		data.NewField("operatorId", nil, id),
+		data.NewField("parentOperatorId", nil, parentID),
+	)
+	frame.RefID = refID
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
+
+// statementHash returns the hash identifying a statement in the plan cache.
+func statementHash(statement string) string {
+	sum := md5.Sum([]byte(statement))
+	return hex.EncodeToString(sum[:])
+}
+
+// queryExplain holds the plan and execution statistics of an explained query.
+type queryExplain struct {
+	plan    *data.Frame
+	stats   []data.QueryStat
+	custom  map[string]any
+	notices []data.Notice
+}
+
+// explainQuery explains an executed query and looks up its plan cache entry. Failures do
+// not fail the query, they are reported as notices. Elapsed is the time of running the
+// query and fetching and converting its rows.
+func (e *DataSourceHandler) explainQuery(ctx context.Context, refID, statement string, elapsed time.Duration, fetchedRows int) *queryExplain {
+	logger := e.log.FromContext(ctx)
+	res := &queryExplain{
+		stats: []data.QueryStat{
+			{FieldConfig: data.FieldConfig{DisplayName: "Query and fetch time", Unit: "ms"}, Value: float64(elapsed.Microseconds()) / 1000},
+			{FieldConfig: data.FieldConfig{DisplayName: "Fetched rows"}, Value: float64(fetchedRows)},
+		},
+		custom: map[string]any{"statementHash": statementHash(statement)},
+	}
+
+	plan, err := e.explainPlan(ctx, statement)
+	if err != nil {
+		res.notices = append(res.notices, data.Notice{Severity: data.NoticeSeverityWarning, Text: fmt.Sprintf("explain plan failed: %v", e.TransformQueryError(logger, err))})
+	} else {
+		res.plan = planFrame(refID, plan)
+	}
+
+	var planID, executions sql.NullInt64
+	var avgTime sql.NullFloat64
+	err = e.db.QueryRowContext(ctx, planCacheQuery, res.custom["statementHash"]).Scan(&planID, &executions, &avgTime)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		res.notices = append(res.notices, data.Notice{Severity: data.NoticeSeverityInfo, Text: fmt.Sprintf("plan cache lookup failed: %v", e.TransformQueryError(logger, err))})
+	default:
+		res.custom["planId"] = planID.Int64
+		res.stats = append(res.stats,
+			data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: "Plan ID"}, Value: float64(planID.Int64)},
+			data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: "Plan executions"}, Value: float64(executions.Int64)},
+			// the plan cache reports microseconds
+			data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: "Server execution time (avg)", Unit: "ms"}, Value: avgTime.Float64 / 1000},
+		)
+	}
+	return res
+}
+
+// attach adds the statistics to the first frame and the plan as further frame.
+func (x *queryExplain) attach(res *backend.DataResponse) {
+	if res.Error != nil {
+		return
+	}
+	if len(res.Frames) > 0 {
+		frame := res.Frames[0]
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.Stats = append(frame.Meta.Stats, x.stats...)
+		frame.Meta.Notices = append(frame.Meta.Notices, x.notices...)
+		if frame.Meta.Custom == nil {
+			frame.Meta.Custom = x.custom
+		}
+	}
+	if x.plan != nil {
+		res.Frames = append(res.Frames, x.plan)
+	}
+}
@@ -0,0 +1,64 @@
+package sqleng
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestPlanFrame(t *testing.T) {
+	plan := []planOperator{
+		{id: 1, level: 1, name: "PROJECT", outputSize: sql.NullFloat64{Float64: 10, Valid: true}},
+		{id: 2, parentID: sql.NullInt64{Int64: 1, Valid: true}, level: 2, name: "COLUMN SEARCH", schema: "SALES", table: "ORDERS", engine: "COLUMN"},
+	}
+	frame := planFrame("A", plan)
+	if frame.RefID != "A" || frame.Rows() != 2 {
+		t.Fatalf("got refId %q and %d rows", frame.RefID, frame.Rows())
+	}
+	if got := frame.Fields[0].At(1); got != "  COLUMN SEARCH" {
+		t.Fatalf("got operator %q", got)
+	}
+	if got := frame.Fields[2].At(1); got != "SALES.ORDERS" {
+		t.Fatalf("got table %q", got)
+	}
+	if got := frame.Fields[4].At(1).(*float64); got != nil {
+		t.Fatalf("expected no output size, got %v", *got)
+	}
+	if got := frame.Fields[7].At(1).(*int64); got == nil || *got != 1 {
+		t.Fatalf("got parent %v", got)
+	}
+}
+
+func TestStatementHash(t *testing.T) {
+	if got := statementHash("SELECT 1 FROM DUMMY"); len(got) != 32 {
+		t.Fatalf("got hash %q", got)
+	}
+	if statementHash("SELECT 1 FROM DUMMY") == statementHash("SELECT 2 FROM DUMMY") {
+		t.Fatal("expected different hashes")
+	}
+}
+
+func TestQueryExplainAttach(t *testing.T) {
+	x := &queryExplain{
+		plan:   planFrame("A", nil),
+		stats:  []data.QueryStat{{FieldConfig: data.FieldConfig{DisplayName: "Fetched rows"}, Value: 3}},
+		custom: map[string]any{"statementHash": "abc"},
+	}
+	res := backend.DataResponse{Frames: data.Frames{data.NewFrame("")}}
+	x.attach(&res)
+	if len(res.Frames) != 2 || res.Frames[1].Name != "explain" {
+		t.Fatalf("got %d frames", len(res.Frames))
+	}
+	meta := res.Frames[0].Meta
+	if meta == nil || len(meta.Stats) != 1 || meta.Custom.(map[string]any)["statementHash"] != "abc" {
+		t.Fatalf("got meta %+v", meta)
+	}
+
+	failed := backend.DataResponse{Error: sql.ErrConnDone}
+	x.attach(&failed)
+	if len(failed.Frames) != 0 {
+		t.Fatal("expected no plan for a failed query")
+	}
+}
@@ -0,0 +1,207 @@
+package sqleng
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Fill modes of $__timeGroup, stored as fillMode in the query json.
+const (
+	fillModeNull     = "null"
+	fillModePrevious = "previous"
+	fillModeValue    = "value"
+	// fillModeLinear interpolates linearly between the neighbouring values.
+	fillModeLinear = "linear"
+	// fillModeNext uses the next value of the series.
+	fillModeNext = "next"
+	// fillModeZeroAfterLast repeats the previous value inside the series and continues with zero
+	// after its last value, for counters which are cleared when they stop reporting.
+	fillModeZeroAfterLast = "zero-after-last"
+)
+
+// maxFillRows limits the rows of a filled frame, every series has a row per bucket of the
+// time range.
+const maxFillRows = 1000000
+
+// AlignOffset returns the offset in seconds of loc at t. Time buckets are aligned to
+// multiples of the interval in this offset, so daily buckets start at local midnight.
+func AlignOffset(loc *time.Location, t time.Time) int64 {
+	_, offset := t.In(loc).Zone()
+	return int64(offset)
+}
+
+// alignTime returns the start of the bucket of t.
+func alignTime(t time.Time, interval time.Duration, offset int64) time.Time {
+	step := int64(interval / time.Second)
+	if step <= 0 {
+		return t
+	}
+	sec := t.Unix() + offset
+	bucket := sec / step * step
+	if sec < 0 && sec%step != 0 {
+		bucket -= step
+	}
+	return time.Unix(bucket-offset, 0).UTC()
+}
+
+// fillOptions describes how missing buckets are filled.
+type fillOptions struct {
+	mode     string
+	value    float64
+	interval time.Duration
+	location *time.Location
+}
+
+// fillValues fills the missing buckets of a series. present marks the buckets with a row,
+// rows with a NULL value are kept as they are.
+func fillValues(values []*float64, present []bool, opts *fillOptions) {
+	n := len(values)
+	// index of the previous and next bucket with a value
+	prev := make([]int, n)
+	next := make([]int, n)
+	last := -1
+	for i := 0; i < n; i++ {
+		prev[i] = last
+		if present[i] && values[i] != nil {
+			last = i
+		}
+	}
+	lastValue := last
+	last = -1
+	for i := n - 1; i >= 0; i-- {
+		next[i] = last
+		if present[i] && values[i] != nil {
+			last = i
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if present[i] {
+			continue
+		}
+		p, nx := prev[i], next[i]
+		switch opts.mode {
+		case fillModeValue:
+			v := opts.value
+			values[i] = &v
+		case fillModePrevious:
+			if p != -1 {
+				values[i] = values[p]
+			}
+		case fillModeNext:
+			if nx != -1 {
+				values[i] = values[nx]
+			}
+		case fillModeLinear:
+			if p != -1 && nx != -1 {
+				v := *values[p] + (*values[nx]-*values[p])*float64(i-p)/float64(nx-p)
+				values[i] = &v
+			}
+		case fillModeZeroAfterLast:
+			switch {
+			case lastValue != -1 && i > lastValue:
+				v := 0.0
+				values[i] = &v
+			case p != -1:
+				values[i] = values[p]
+			}
+		}
+	}
+}
+
+// fillFrame resamples every series of a wide or long frame onto the buckets of the time
+// range and fills the missing buckets. String columns identify the series, numeric and
+// boolean columns are values, other columns are dropped. The result is a long frame sorted
+// by time, in which every series has a row for every bucket, so no gaps appear when it is
+// converted to wide or multi frames.
+func fillFrame(frame *data.Frame, timeIdx int, opts *fillOptions, tr backend.TimeRange) (*data.Frame, error) {
+	if opts.interval < time.Second {
+		return nil, fmt.Errorf("fill interval %v is too small", opts.interval)
+	}
+	var dims []*data.Field
+	var values []int
+	for i, f := range frame.Fields {
+		switch {
+		case i == timeIdx:
+		case isStringField(f):
+			dims = append(dims, f)
+		case isValueField(f):
+			values = append(values, i)
+		}
+	}
+
+	offset := AlignOffset(opts.location, tr.From)
+	from := alignTime(tr.From, opts.interval, offset)
+	buckets := int(tr.To.Sub(from)/opts.interval) + 1
+	if buckets < 1 {
+		buckets = 1
+	}
+
+	series := groupTimeSeries(frame, timeIdx, dims)
+	if rows := int64(buckets) * int64(max(len(series), 1)); rows > maxFillRows {
+		return nil, fmt.Errorf("filling %d buckets of %v for %d series exceeds the limit of %d rows, use a larger interval or a shorter time range",
+			buckets, opts.interval, len(series), maxFillRows)
+	}
+	timeField := frame.Fields[timeIdx]
+
+	out := data.NewFrame(frame.Name, data.NewFieldFromFieldType(data.FieldTypeTime, 0))
+	out.Fields[0].Name = timeField.Name
+	out.Fields[0].Config = timeField.Config
+	for _, d := range dims {
+		f := data.NewFieldFromFieldType(d.Type(), 0)
+		f.Name, f.Config = d.Name, d.Config
+		out.Fields = append(out.Fields, f)
+	}
+	for _, vi := range values {
+		f := data.NewFieldFromFieldType(data.FieldTypeNullableFloat64, 0)
+		f.Name, f.Labels, f.Config = frame.Fields[vi].Name, frame.Fields[vi].Labels, frame.Fields[vi].Config
+		out.Fields = append(out.Fields, f)
+	}
+
+	filled := make([][][]*float64, len(series))
+	for si, s := range series {
+		present := make([]bool, buckets)
+		filled[si] = make([][]*float64, len(values))
+		for j := range values {
+			filled[si][j] = make([]*float64, buckets)
+		}
+		for _, row := range s.rows {
+			t, _ := timeField.ConcreteAt(row)
+			b := int(t.(time.Time).Sub(from) / opts.interval)
+			if b < 0 || b >= buckets {
+				continue
+			}
+			present[b] = true
+			for j, vi := range values {
+				v, err := frame.Fields[vi].NullableFloatAt(row)
+				if err != nil {
+					return nil, err
+				}
+				filled[si][j][b] = v
+			}
+		}
+		for j := range values {
+			fillValues(filled[si][j], present, opts)
+		}
+	}
+
+	for b := 0; b < buckets; b++ {
+		t := from.Add(time.Duration(b) * opts.interval)
+		for si, s := range series {
+			row := make([]interface{}, 0, len(out.Fields))
+			row = append(row, t)
+			for _, d := range dims {
+				row = append(row, d.At(s.rows[0]))
+			}
+			for j := range values {
+				row = append(row, filled[si][j][b])
+			}
+			out.AppendRow(row...)
+		}
+	}
+	out.Meta = frame.Meta
+	return out, nil
+}
@@ -0,0 +1,87 @@
+package sqleng
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestFillValues(t *testing.T) {
+	present := []bool{false, true, false, false, true, false}
+	tests := []struct {
+		mode string
+		want []*float64
+	}{
+		{mode: fillModeNull, want: []*float64{nil, fp(1), nil, nil, fp(4), nil}},
+		{mode: fillModeValue, want: []*float64{fp(7), fp(1), fp(7), fp(7), fp(4), fp(7)}},
+		{mode: fillModePrevious, want: []*float64{nil, fp(1), fp(1), fp(1), fp(4), fp(4)}},
+		{mode: fillModeNext, want: []*float64{fp(1), fp(1), fp(4), fp(4), fp(4), nil}},
+		{mode: fillModeLinear, want: []*float64{nil, fp(1), fp(2), fp(3), fp(4), nil}},
+		{mode: fillModeZeroAfterLast, want: []*float64{nil, fp(1), fp(1), fp(1), fp(4), fp(0)}},
+	}
+	for _, tt := range tests {
+		values := []*float64{nil, fp(1), nil, nil, fp(4), nil}
+		fillValues(values, present, &fillOptions{mode: tt.mode, value: 7})
+		for i := range values {
+			if (values[i] == nil) != (tt.want[i] == nil) || (values[i] != nil && *values[i] != *tt.want[i]) {
+				t.Fatalf("%s: bucket %d is %v, want %v", tt.mode, i, values[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestAlignTime(t *testing.T) {
+	berlin := ParseLocation("Europe/Berlin")
+	ts := time.Date(2024, 1, 31, 0, 30, 0, 0, berlin)
+	got := alignTime(ts, 24*time.Hour, AlignOffset(berlin, ts))
+	if want := time.Date(2024, 1, 31, 0, 0, 0, 0, berlin); !got.Equal(want) {
+		t.Fatalf("got %v, want local midnight %v", got, want)
+	}
+	if got := alignTime(ts, time.Hour, 0); !got.Equal(time.Date(2024, 1, 30, 23, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected hour bucket %v", got)
+	}
+}
+
+func TestFillFrameLong(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	frame := data.NewFrame("",
+		data.NewField("Time", nil, []time.Time{t0, t0, t0.Add(3 * time.Minute)}),
+		data.NewField("HOST", nil, []string{"a", "b", "a"}),
+		data.NewField("CPU", nil, []*float64{fp(1), fp(5), fp(4)}),
+	)
+	opts := &fillOptions{mode: fillModeLinear, interval: time.Minute, location: time.UTC}
+	tr := backend.TimeRange{From: t0.Add(10 * time.Second), To: t0.Add(3 * time.Minute)}
+	filled, err := fillFrame(frame, 0, opts, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4 buckets for 2 series
+	if filled.Rows() != 8 {
+		t.Fatalf("got %d rows, want 8", filled.Rows())
+	}
+	// second bucket of host a is interpolated, host b has no later value
+	if v := filled.Fields[2].At(2).(*float64); v == nil || *v != 2 {
+		t.Fatalf("unexpected interpolated value %v", v)
+	}
+	if v := filled.Fields[2].At(3).(*float64); v != nil {
+		t.Fatalf("expected null after the last value, got %v", *v)
+	}
+	if !filled.Fields[0].At(0).(time.Time).Equal(t0) {
+		t.Fatalf("first bucket %v is not aligned", filled.Fields[0].At(0))
+	}
+
+	year := backend.TimeRange{From: t0, To: t0.AddDate(1, 0, 0)}
+	if _, err := fillFrame(frame, 0, &fillOptions{mode: fillModeNull, interval: time.Second, location: time.UTC}, year); err == nil {
+		t.Fatal("expected filling a year of seconds to be rejected")
+	}
+
+	wide, err := data.LongToWide(filled, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wide.Rows() != 4 {
+		t.Fatalf("got %d wide rows, want 4", wide.Rows())
+	}
+}
@@ -0,0 +1,152 @@
+package sqleng
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
+)
+
+// LimitedScanner is implemented by scan destinations that read at most a
+// configurable number of bytes per value, such as LOB columns. The frame
+// builder sets the limit before every scan and reports truncated values.
+type LimitedScanner interface {
+	sql.Scanner
+	SetLimit(n int64)
+	Truncated() bool
+}
+
+// frameLimits bounds the size of a frame built from sql rows. A limit <= 0 disables the check.
+type frameLimits struct {
+	rows      int64
+	bytes     int64
+	lobLength int64
+}
+
+// frameFromRows works like sqlutil.FrameFromRows, but also tracks the approximate
+// memory used by the frame and stops scanning once the byte budget is exhausted.
+// Values scanned through a LimitedScanner are capped to limits.lobLength bytes.
+func frameFromRows(rows *sql.Rows, limits frameLimits, converters ...sqlutil.Converter) (*data.Frame, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	scanRow, err := sqlutil.MakeScanRow(types, names, converters...)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := sqlutil.NewFrame(names, scanRow.Converters...)
+
+	var i, size, truncated int64
+	budgetExceeded := false
+	for {
+		// first iterate over rows may be nop if not switched result set to next
+		for rows.Next() {
+			if limits.rows > 0 && i == limits.rows {
+				frame.AppendNotices(data.Notice{
+					Severity: data.NoticeSeverityWarning,
+					Text:     fmt.Sprintf("Results have been limited to %v because the SQL row limit was reached", limits.rows),
+				})
+				break
+			}
+
+			r := scanRow.NewScannableRow()
+			for _, v := range r {
+				if s, ok := v.(LimitedScanner); ok {
+					s.SetLimit(limits.lobLength)
+				}
+			}
+			if err := rows.Scan(r...); err != nil {
+				return nil, err
+			}
+			for _, v := range r {
+				if s, ok := v.(LimitedScanner); ok && s.Truncated() {
+					truncated++
+				}
+			}
+
+			if err := sqlutil.Append(frame, r, scanRow.Converters...); err != nil {
+				return nil, err
+			}
+			i++
+
+			size += lastRowSize(frame)
+			if limits.bytes > 0 && size >= limits.bytes {
+				budgetExceeded = true
+				frame.AppendNotices(data.Notice{
+					Severity: data.NoticeSeverityWarning,
+					Text:     fmt.Sprintf("Results have been limited to %v rows because the frame size limit of %v bytes was reached", i, limits.bytes),
+				})
+				break
+			}
+		}
+		if (limits.rows > 0 && i == limits.rows) || budgetExceeded || !rows.NextResultSet() {
+			break
+		}
+	}
+
+	if truncated > 0 {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("%v values have been truncated to %v bytes", truncated, limits.lobLength),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return frame, backend.DownstreamError(err)
+	}
+
+	return frame, nil
+}
+
+// lastRowSize returns the approximate number of bytes used by the last row of the frame.
+func lastRowSize(frame *data.Frame) int64 {
+	n := frame.Rows()
+	if n == 0 {
+		return 0
+	}
+	var size int64
+	for _, field := range frame.Fields {
+		size += approxValueSize(field.At(n - 1))
+	}
+	return size
+}
+
+// approxValueSize estimates the memory used by a single frame value.
+func approxValueSize(v any) int64 {
+	switch x := v.(type) {
+	case nil:
+		return 8
+	case string:
+		return int64(len(x)) + 16
+	case *string:
+		if x == nil {
+			return 8
+		}
+		return int64(len(*x)) + 24
+	case []byte:
+		return int64(len(x)) + 24
+	case json.RawMessage:
+		return int64(len(x)) + 24
+	case *json.RawMessage:
+		if x == nil {
+			return 8
+		}
+		return int64(len(*x)) + 32
+	case time.Time, *time.Time:
+		return 32
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,420 @@
+package sqleng
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
+)
+
+// maxRefreshEntries bounds the executions remembered to enforce the refresh interval.
+const maxRefreshEntries = 10000
+
+type sqlTokenKind int
+
+const (
+	tokenWord sqlTokenKind = iota
+	tokenQuoted
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+// sqlToken is a token of a statement. Words are upper-cased like HANA folds unquoted
+// identifiers, quoted identifiers keep their case. Start and end are rune offsets.
+type sqlToken struct {
+	kind       sqlTokenKind
+	text       string
+	start, end int
+}
+
+func (t sqlToken) is(kind sqlTokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+func (t sqlToken) identifier() bool {
+	return t.kind == tokenWord || t.kind == tokenQuoted
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '$' || r == '#' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenizeSQL splits a statement into tokens. Comments are dropped.
+func tokenizeSQL(sql string) []sqlToken {
+	var tokens []sqlToken
+	runes := []rune(sql)
+	// quoted reads a literal or identifier ending with q, doubled quotes escape it
+	quoted := func(i int, q rune) (string, int) {
+		var sb strings.Builder
+		for i++; i < len(runes); i++ {
+			if runes[i] == q {
+				if i+1 < len(runes) && runes[i+1] == q {
+					sb.WriteRune(q)
+					i++
+					continue
+				}
+				return sb.String(), i + 1
+			}
+			sb.WriteRune(runes[i])
+		}
+		return sb.String(), i
+	}
+	for i := 0; i < len(runes); {
+		r, start := runes[i], i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i < len(runes) && !(runes[i-1] == '*' && runes[i] == '/'); i++ {
+			}
+			i++
+		case r == '\'':
+			var s string
+			s, i = quoted(i, '\'')
+			tokens = append(tokens, sqlToken{tokenString, s, start, i})
+		case r == '"':
+			var s string
+			s, i = quoted(i, '"')
+			tokens = append(tokens, sqlToken{tokenQuoted, s, start, i})
+		case unicode.IsDigit(r):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenNumber, string(runes[start:i]), start, i})
+		case isWordRune(r):
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenWord, strings.ToUpper(string(runes[start:i])), start, i})
+		default:
+			i++
+			tokens = append(tokens, sqlToken{tokenSymbol, string(r), start, i})
+		}
+	}
+	return tokens
+}
+
+// tableReference is a table, view or table function read by a statement. The schema is
+// empty if the name is not qualified. First and last are the tokens of the name.
+type tableReference struct {
+	schema      string
+	name        string
+	first, last int
+}
+
+func (r tableReference) String() string {
+	if r.schema == "" {
+		return r.name
+	}
+	return r.schema + "." + r.name
+}
+
+// tableClauseKeywords start a list of table references in a query, tableClauseEnd ends it.
+var (
+	tableClauseKeywords = []string{"FROM", "JOIN"}
+	tableClauseEnd      = []string{"WHERE", "GROUP", "ORDER", "HAVING", "LIMIT", "UNION", "EXCEPT", "INTERSECT",
+		"MINUS", "WITH", "FOR"}
+)
+
+// closingParen returns the index of the parenthesis closing the one at open.
+func closingParen(tokens []sqlToken, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is(tokenSymbol, "("):
+			depth++
+		case tokens[i].is(tokenSymbol, ")"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// commonTableExpressions returns the names of the common table expressions of the WITH
+// clause at i by the index of the parenthesis closing their query.
+func commonTableExpressions(tokens []sqlToken, i int) map[int]string {
+	ctes := map[int]string{}
+	// i is the token before the name, WITH or the comma separating the expressions
+	for i+1 < len(tokens) && tokens[i+1].identifier() {
+		i++
+		name := tokens[i].text
+		if i+1 < len(tokens) && tokens[i+1].is(tokenSymbol, "(") {
+			i = closingParen(tokens, i+1)
+		}
+		if i+2 >= len(tokens) || !tokens[i+1].is(tokenWord, "AS") || !tokens[i+2].is(tokenSymbol, "(") {
+			break
+		}
+		i = closingParen(tokens, i+2)
+		ctes[i] = name
+		if i+1 >= len(tokens) || !tokens[i+1].is(tokenSymbol, ",") {
+			break
+		}
+		i++
+	}
+	return ctes
+}
+
+// tableReferences returns the objects referenced by the FROM and JOIN clauses of a
+// statement, including those of subqueries and parenthesized joins. Common table
+// expressions are not returned. Table lists it cannot read are an error, so no reference
+// is missed.
+func tableReferences(tokens []sqlToken) ([]tableReference, error) {
+	// FROM is also an argument separator of functions like EXTRACT, so it only starts a
+	// table list in the parentheses of a subquery. A common table expression is visible in
+	// the parentheses of its WITH clause after its own query.
+	type clause struct {
+		query, inList, expectTable bool
+		ctes                       map[string]bool
+	}
+	stack := []clause{{}}
+	isCTE := func(name string) bool {
+		for _, c := range stack {
+			if c.ctes[name] {
+				return true
+			}
+		}
+		return false
+	}
+	pendingCTEs := map[int]string{}
+	var refs []tableReference
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		top := &stack[len(stack)-1]
+		switch {
+		case top.expectTable && !t.identifier() && !t.is(tokenSymbol, "("):
+			return nil, fmt.Errorf("cannot read the table list of the query at %q", t.text)
+		case t.is(tokenSymbol, "("):
+			// a parenthesis in a table list starts a subquery or a nested table list
+			nested := top.expectTable && i+1 < len(tokens) && !tokens[i+1].is(tokenWord, "SELECT") && !tokens[i+1].is(tokenWord, "WITH")
+			top.expectTable = false
+			stack = append(stack, clause{query: nested, inList: nested, expectTable: nested})
+		case t.is(tokenSymbol, ")"):
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unbalanced parentheses in the query")
+			}
+			stack = stack[:len(stack)-1]
+			if name, ok := pendingCTEs[i]; ok {
+				top = &stack[len(stack)-1]
+				if top.ctes == nil {
+					top.ctes = map[string]bool{}
+				}
+				top.ctes[name] = true
+			}
+		case t.is(tokenSymbol, ","):
+			top.expectTable = top.inList
+		case t.is(tokenWord, "WITH") && (i == 0 || tokens[i-1].is(tokenSymbol, "(")):
+			for end, name := range commonTableExpressions(tokens, i) {
+				pendingCTEs[end] = name
+			}
+			top.inList, top.expectTable = false, false
+		case t.is(tokenWord, "SELECT"):
+			top.query, top.inList, top.expectTable = true, false, false
+		case top.query && t.kind == tokenWord && slices.Contains(tableClauseKeywords, t.text):
+			top.inList, top.expectTable = true, true
+		case t.kind == tokenWord && slices.Contains(tableClauseEnd, t.text):
+			top.inList, top.expectTable = false, false
+		case top.expectTable && t.identifier():
+			ref := tableReference{first: i}
+			parts := []string{t.text}
+			for i+2 < len(tokens) && tokens[i+1].is(tokenSymbol, ".") && tokens[i+2].identifier() {
+				parts = append(parts, tokens[i+2].text)
+				i += 2
+			}
+			top.expectTable = false
+			ref.last, ref.name = i, parts[len(parts)-1]
+			if len(parts) > 1 {
+				ref.schema = parts[len(parts)-2]
+			}
+			if ref.schema == "" && isCTE(ref.name) {
+				continue
+			}
+			refs = append(refs, ref)
+		}
+	}
+	if len(stack) > 1 || stack[0].expectTable {
+		return nil, fmt.Errorf("the query ends in a table list or parenthesis")
+	}
+	return refs, nil
+}
+
+// checkSelectStatement rejects statements other than queries, which may read tables
+// the statement does not reference, like procedure calls.
+func checkSelectStatement(tokens []sqlToken) error {
+	if len(tokens) > 0 && !tokens[0].is(tokenWord, "SELECT") && !tokens[0].is(tokenWord, "WITH") && !tokens[0].is(tokenSymbol, "(") {
+		return fmt.Errorf("only SELECT statements are allowed by this datasource, not %s", tokens[0].text)
+	}
+	return nil
+}
+
+// publicObject reports whether an unqualified object is available to every user, like
+// DUMMY or the series generator functions.
+func publicObject(ref tableReference) bool {
+	return ref.schema == "" && (ref.name == "DUMMY" || strings.HasPrefix(ref.name, "SERIES_GENERATE_"))
+}
+
+// queryGovernance enforces the limits a datasource puts on the queries of its users.
+type queryGovernance struct {
+	allowedSchemas     []string
+	allowedTables      []string
+	defaultSchema      string
+	maxTimeRange       time.Duration
+	minRefreshInterval time.Duration
+
+	mu      sync.Mutex
+	lastRun map[string]time.Time
+	now     func() time.Time
+}
+
+// newQueryGovernance reads the governance settings of a datasource.
+func newQueryGovernance(jd JsonData) (*queryGovernance, error) {
+	g := &queryGovernance{
+		allowedSchemas: jd.AllowedSchemas,
+		allowedTables:  jd.AllowedTables,
+		defaultSchema:  jd.DefaultSchema,
+		lastRun:        map[string]time.Time{},
+		now:            time.Now,
+	}
+	var err error
+	if jd.MaxTimeRange != "" {
+		if g.maxTimeRange, err = gtime.ParseDuration(jd.MaxTimeRange); err != nil {
+			return nil, fmt.Errorf("invalid maximum time range %q: %w", jd.MaxTimeRange, err)
+		}
+	}
+	if jd.MinRefreshInterval != "" {
+		if g.minRefreshInterval, err = gtime.ParseDuration(jd.MinRefreshInterval); err != nil {
+			return nil, fmt.Errorf("invalid minimum refresh interval %q: %w", jd.MinRefreshInterval, err)
+		}
+	}
+	return g, nil
+}
+
+// tableAllowed reports whether a reference is in an allowed schema or an allowed table.
+// Unqualified names are resolved in the default schema.
+func (g *queryGovernance) tableAllowed(ref tableReference) bool {
+	if publicObject(ref) {
+		return true
+	}
+	schema := ref.schema
+	if schema == "" {
+		schema = g.defaultSchema
+	}
+	if schema == "" {
+		return false
+	}
+	if slices.ContainsFunc(g.allowedSchemas, func(s string) bool { return strings.EqualFold(s, schema) }) {
+		return true
+	}
+	return slices.ContainsFunc(g.allowedTables, func(t string) bool {
+		s, name, ok := strings.Cut(t, ".")
+		return ok && strings.EqualFold(s, schema) && strings.EqualFold(name, ref.name)
+	})
+}
+
+// checkTables rejects statements reading tables outside of the allowed schemas and tables.
+// Only queries are allowed if the tables are restricted. HANA resolves an unqualified name
+// missing in the default schema to a public synonym, like USERS to SYS.USERS, so unqualified
+// names are rejected unless tableColumns finds them in the default schema.
+func (g *queryGovernance) checkTables(sql string, tableColumns func(tableReference) ([]CatalogColumn, error)) error {
+	if g == nil || (len(g.allowedSchemas) == 0 && len(g.allowedTables) == 0) {
+		return nil
+	}
+	tokens := tokenizeSQL(sql)
+	if err := checkSelectStatement(tokens); err != nil {
+		return err
+	}
+	refs, err := tableReferences(tokens)
+	if err != nil {
+		return err
+	}
+	var denied []string
+	for _, ref := range refs {
+		if !g.tableAllowed(ref) && !slices.Contains(denied, ref.String()) {
+			denied = append(denied, ref.String())
+		}
+	}
+	if len(denied) > 0 {
+		allowed := append(slices.Clone(g.allowedSchemas), g.allowedTables...)
+		return fmt.Errorf("access to %s is not allowed by this datasource, the allowed schemas and tables are %s",
+			strings.Join(denied, ", "), strings.Join(allowed, ", "))
+	}
+	for _, ref := range refs {
+		if ref.schema != "" || publicObject(ref) {
+			continue
+		}
+		if _, err := tableColumns(tableReference{schema: g.defaultSchema, name: ref.name}); err != nil {
+			return fmt.Errorf("%s is not a table or view of the default schema %s, qualify it with its schema: %w",
+				ref, g.defaultSchema, err)
+		}
+	}
+	return nil
+}
+
+// checkQuery rejects queries over a longer time range than allowed and queries refreshed
+// more often than allowed. Alert rules are evaluated at the interval set by their
+// administrators, so their refreshes are not limited. A query counts as refreshed once
+// recordRun recorded it.
+func (g *queryGovernance) checkQuery(query backend.DataQuery, origin queryOrigin) error {
+	if g == nil {
+		return nil
+	}
+	span := query.TimeRange.Duration()
+	if g.maxTimeRange > 0 && span > g.maxTimeRange {
+		return fmt.Errorf("the time range of %s exceeds the maximum of %s allowed by this datasource",
+			gtime.FormatInterval(span), gtime.FormatInterval(g.maxTimeRange))
+	}
+	if !g.limitsRefresh(origin) {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	if last, ok := g.lastRun[refreshKey(query, origin)]; ok && now.Sub(last) < g.minRefreshInterval {
+		return fmt.Errorf("the query was refreshed after %s, this datasource allows refreshing it every %s at most",
+			gtime.FormatInterval(now.Sub(last).Round(time.Second)), gtime.FormatInterval(g.minRefreshInterval))
+	}
+	return nil
+}
+
+// recordRun records the run of a query accepted for execution, which starts the refresh
+// interval of the query.
+func (g *queryGovernance) recordRun(query backend.DataQuery, origin queryOrigin) {
+	if g == nil || !g.limitsRefresh(origin) {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	if len(g.lastRun) >= maxRefreshEntries {
+		for k, t := range g.lastRun {
+			if now.Sub(t) >= g.minRefreshInterval {
+				delete(g.lastRun, k)
+			}
+		}
+	}
+	g.lastRun[refreshKey(query, origin)] = now
+}
+
+func (g *queryGovernance) limitsRefresh(origin queryOrigin) bool {
+	return g.minRefreshInterval > 0 && !origin.fromAlert
+}
+
+// refreshKey identifies the refreshes of a query, which re-run the same query over a time
+// range of the same length.
+func refreshKey(query backend.DataQuery, origin queryOrigin) string {
+	return strings.Join([]string{origin.user, origin.dashboardUID, origin.panelID, query.RefID,
+		statementHash(string(query.JSON)), query.TimeRange.Duration().Round(time.Second).String()}, "\x00")
+}
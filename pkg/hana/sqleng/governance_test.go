@@ -0,0 +1,150 @@
+package sqleng
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestTableReferences(t *testing.T) {
+	sql := `WITH recent AS (SELECT * FROM sales.orders WHERE "ts" > ADD_DAYS(NOW(), -1))
+SELECT EXTRACT(YEAR FROM r."ts"), c.name -- FROM hidden.comment
+FROM recent r JOIN "Sales"."Customers" c ON c.id = r.customer_id, DUMMY,
+  (SELECT id FROM /* other.comment */ fin.ledger) l
+WHERE r.note <> 'FROM other.literal' AND r.id IN (SELECT id FROM archive.orders)
+UNION ALL SELECT 1, 'x' FROM SERIES_GENERATE_INTEGER(1, 0, 10)`
+	references := func(sql string) string {
+		refs, err := tableReferences(tokenizeSQL(sql))
+		if err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		var got []string
+		for _, ref := range refs {
+			got = append(got, ref.String())
+		}
+		return strings.Join(got, " ")
+	}
+	for sql, want := range map[string]string{
+		sql: "SALES.ORDERS Sales.Customers DUMMY FIN.LEDGER ARCHIVE.ORDERS SERIES_GENERATE_INTEGER",
+		"SELECT * FROM (APP.T a INNER JOIN (SYS.USERS) u ON 1=1), ((SELECT 1 FROM DUMMY) d JOIN APP.S s ON 1=1)": "APP.T SYS.USERS DUMMY APP.S",
+		// the query of a common table expression does not see its name
+		"WITH users AS (SELECT * FROM users), u2 (id) AS (SELECT id FROM users) SELECT * FROM u2": "USERS",
+		"SELECT * FROM users, (WITH users AS (SELECT 1 FROM DUMMY) SELECT * FROM users) x":        "USERS DUMMY",
+	} {
+		if got := references(sql); got != want {
+			t.Fatalf("%s: got %s, want %s", sql, got, want)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT * FROM :tab",
+		"SELECT * FROM APP.T, ",
+		"SELECT * FROM (APP.T",
+		"SELECT 1 FROM DUMMY) UNION SELECT * FROM SYS.USERS (",
+	} {
+		if _, err := tableReferences(tokenizeSQL(sql)); err == nil {
+			t.Fatalf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestCheckTables(t *testing.T) {
+	g, err := newQueryGovernance(JsonData{AllowedSchemas: []string{"SALES"}, AllowedTables: []string{"FIN.LEDGER"}, DefaultSchema: "SALES"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := func(ref tableReference) ([]CatalogColumn, error) {
+		if ref.schema == "SALES" && ref.name == "ORDERS" {
+			return []CatalogColumn{{Name: "ID", Type: "INTEGER"}}, nil
+		}
+		return nil, errors.New("not found")
+	}
+	for _, sql := range []string{
+		"SELECT * FROM orders",
+		"SELECT * FROM sales.orders o JOIN fin.ledger l ON o.id = l.id",
+		"SELECT 1 FROM DUMMY",
+	} {
+		if err := g.checkTables(sql, catalog); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	err = g.checkTables("SELECT * FROM sales.orders, fin.accounts a, (SELECT * FROM hr.salaries) s", catalog)
+	if err == nil || !strings.Contains(err.Error(), "access to FIN.ACCOUNTS, HR.SALARIES is not allowed") {
+		t.Fatalf("got %v", err)
+	}
+	if err := g.checkTables("CALL hr.export()", catalog); err == nil {
+		t.Fatal("expected a procedure call to be rejected")
+	}
+	for _, sql := range []string{
+		"SELECT * FROM (APP.T a INNER JOIN SYS.USERS u ON 1=1)",
+		"SELECT * FROM (SYS.USERS)",
+		"SELECT * FROM sales.orders, :other",
+		// public synonyms of system views
+		"SELECT * FROM USERS",
+		"SELECT * FROM orders o JOIN M_TABLES t ON 1=1",
+	} {
+		if err := g.checkTables(sql, catalog); err == nil {
+			t.Fatalf("%s: expected the query to be rejected", sql)
+		}
+	}
+
+	var unrestricted *queryGovernance
+	if err := unrestricted.checkTables("SELECT * FROM hr.salaries", catalog); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckQuery(t *testing.T) {
+	g, err := newQueryGovernance(JsonData{MaxTimeRange: "7d", MinRefreshInterval: "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+	query := backend.DataQuery{
+		RefID:     "A",
+		JSON:      json.RawMessage(`{"rawSql":"SELECT 1 FROM DUMMY"}`),
+		TimeRange: backend.TimeRange{From: now.Add(-time.Hour), To: now},
+	}
+	origin := queryOrigin{dashboardUID: "sales", user: "viewer"}
+
+	if err := g.checkQuery(query, origin); err != nil {
+		t.Fatal(err)
+	}
+	// a query rejected later, for example by the cost guard, is not recorded
+	if err := g.checkQuery(query, origin); err != nil {
+		t.Fatalf("refresh of an unrecorded query: %v", err)
+	}
+	g.recordRun(query, origin)
+	g.recordRun(query, queryOrigin{fromAlert: true})
+	now = now.Add(30 * time.Second)
+	query.TimeRange = backend.TimeRange{From: now.Add(-time.Hour), To: now}
+	if err := g.checkQuery(query, origin); err == nil || !strings.Contains(err.Error(), "every 1m at most") {
+		t.Fatalf("got %v", err)
+	}
+	if err := g.checkQuery(query, queryOrigin{dashboardUID: "sales", user: "editor"}); err != nil {
+		t.Fatalf("another user refreshed: %v", err)
+	}
+	if err := g.checkQuery(query, queryOrigin{fromAlert: true}); err != nil {
+		t.Fatalf("alert rule refreshed: %v", err)
+	}
+	now = now.Add(time.Minute)
+	query.TimeRange = backend.TimeRange{From: now.Add(-time.Hour), To: now}
+	if err := g.checkQuery(query, origin); err != nil {
+		t.Fatal(err)
+	}
+
+	query.TimeRange = backend.TimeRange{From: now.Add(-30 * 24 * time.Hour), To: now}
+	if err := g.checkQuery(query, origin); err == nil || !strings.Contains(err.Error(), "exceeds the maximum of 7d") {
+		t.Fatalf("got %v", err)
+	}
+
+	if _, err := newQueryGovernance(JsonData{MaxTimeRange: "a week"}); err == nil {
+		t.Fatal("expected an invalid setting to fail")
+	}
+}
@@ -0,0 +1,297 @@
+package sqleng
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const (
+	defaultResourceLimit = 1000
+	maxResourceLimit     = 10000
+)
+
+// catalogObject describes a catalog listing served by the resource API.
+type catalogObject struct {
+	// from is the catalog view the objects are read from.
+	from string
+	// columns are the selected catalog columns, keys the JSON keys of the items.
+	columns []string
+	keys    []string
+	// nameColumn is the column filtered and sorted by.
+	nameColumn string
+	// schemaColumn restricts the objects to a schema, unless empty.
+	schemaColumn string
+	// tableColumn restricts the objects to the table parameter, unless empty.
+	tableColumn string
+	// where is an additional fixed condition.
+	where string
+}
+
+var catalogObjects = map[string]catalogObject{
+	"schemas": {
+		from:       "SYS.SCHEMAS",
+		columns:    []string{"SCHEMA_NAME", "SCHEMA_OWNER"},
+		keys:       []string{"name", "owner"},
+		nameColumn: "SCHEMA_NAME",
+		where:      "HAS_PRIVILEGES = 'TRUE'",
+	},
+	"tables": {
+		from:         "SYS.TABLES",
+		columns:      []string{"TABLE_NAME", "TABLE_TYPE", "COMMENTS"},
+		keys:         []string{"name", "type", "comment"},
+		nameColumn:   "TABLE_NAME",
+		schemaColumn: "SCHEMA_NAME",
+		where:        "IS_SYSTEM_TABLE = 'FALSE'",
+	},
+	"views": {
+		from:         "SYS.VIEWS",
+		columns:      []string{"VIEW_NAME", "VIEW_TYPE", "COMMENTS"},
+		keys:         []string{"name", "type", "comment"},
+		nameColumn:   "VIEW_NAME",
+		schemaColumn: "SCHEMA_NAME",
+	},
+	"calculation-views": {
+		from:         "SYS.VIEWS",
+		columns:      []string{"VIEW_NAME", "COMMENTS"},
+		keys:         []string{"name", "comment"},
+		nameColumn:   "VIEW_NAME",
+		schemaColumn: "SCHEMA_NAME",
+		where:        "VIEW_TYPE = 'CALC'",
+	},
+	"synonyms": {
+		from:         "SYS.SYNONYMS",
+		columns:      []string{"SYNONYM_NAME", "OBJECT_SCHEMA", "OBJECT_NAME", "OBJECT_TYPE"},
+		keys:         []string{"name", "objectSchema", "objectName", "objectType"},
+		nameColumn:   "SYNONYM_NAME",
+		schemaColumn: "SCHEMA_NAME",
+	},
+	"columns": {
+		// the columns of a table or a view, resolved from the name in the table parameter
+		from: "(SELECT SCHEMA_NAME, TABLE_NAME, COLUMN_NAME, POSITION, DATA_TYPE_NAME, LENGTH, SCALE, IS_NULLABLE, COMMENTS FROM SYS.TABLE_COLUMNS" +
+			" UNION ALL SELECT SCHEMA_NAME, VIEW_NAME, COLUMN_NAME, POSITION, DATA_TYPE_NAME, LENGTH, SCALE, IS_NULLABLE, COMMENTS FROM SYS.VIEW_COLUMNS) AS C",
+		columns:      []string{"COLUMN_NAME", "DATA_TYPE_NAME", "LENGTH", "SCALE", "IS_NULLABLE", "COMMENTS"},
+		keys:         []string{"name", "type", "length", "scale", "nullable", "comment"},
+		nameColumn:   "COLUMN_NAME",
+		schemaColumn: "SCHEMA_NAME",
+		tableColumn:  "TABLE_NAME",
+	},
+}
+
+// catalogRequest holds the parameters of a catalog listing.
+type catalogRequest struct {
+	schema string
+	table  string
+	filter string
+	limit  int
+	offset int
+}
+
+// catalogResponse is the body of a catalog listing. HasMore is set when there are objects
+// after the returned page.
+type catalogResponse struct {
+	Schema  string           `json:"schema,omitempty"`
+	Items   []map[string]any `json:"items"`
+	Limit   int              `json:"limit"`
+	Offset  int              `json:"offset"`
+	HasMore bool             `json:"hasMore"`
+}
+
+// likePattern turns a filter into a case-insensitive LIKE pattern. A filter with * matches
+// the wildcard pattern, any other filter matches names containing it.
+func likePattern(filter string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	p := r.Replace(strings.ToUpper(filter))
+	if strings.Contains(p, "*") {
+		return strings.ReplaceAll(p, "*", "%")
+	}
+	return "%" + p + "%"
+}
+
+// parseCatalogRequest reads the listing parameters. The schema defaults to the default
+// schema of the data source, the current schema of the connection if none is configured.
+func parseCatalogRequest(values url.Values, defaultSchema string) (catalogRequest, error) {
+	req := catalogRequest{
+		schema: values.Get("schema"),
+		table:  values.Get("table"),
+		filter: values.Get("filter"),
+		limit:  defaultResourceLimit,
+	}
+	if req.schema == "" {
+		req.schema = defaultSchema
+	}
+	if s := values.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxResourceLimit {
+			return req, fmt.Errorf("limit must be a number between 1 and %d", maxResourceLimit)
+		}
+		req.limit = limit
+	}
+	if s := values.Get("offset"); s != "" {
+		offset, err := strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return req, fmt.Errorf("offset must be a positive number")
+		}
+		req.offset = offset
+	}
+	return req, nil
+}
+
+// query returns the catalog query of a listing and its parameters. One row more than the
+// limit is read to detect further pages.
+func (o catalogObject) query(req catalogRequest) (string, []any) {
+	var conditions []string
+	var args []any
+	if o.where != "" {
+		conditions = append(conditions, o.where)
+	}
+	if o.schemaColumn != "" {
+		if req.schema == "" {
+			conditions = append(conditions, o.schemaColumn+" = CURRENT_SCHEMA")
+		} else {
+			conditions = append(conditions, o.schemaColumn+" = ?")
+			args = append(args, req.schema)
+		}
+	}
+	if o.tableColumn != "" {
+		conditions = append(conditions, o.tableColumn+" = ?")
+		args = append(args, req.table)
+	}
+	if req.filter != "" {
+		conditions = append(conditions, "UPPER("+o.nameColumn+`) LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(req.filter))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(o.columns, ", "))
+	sb.WriteString(" FROM ")
+	sb.WriteString(o.from)
+	if len(conditions) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(conditions, " AND "))
+	}
+	order := o.nameColumn
+	if o.tableColumn != "" {
+		order = "POSITION"
+	}
+	fmt.Fprintf(&sb, " ORDER BY %s LIMIT %d OFFSET %d", order, req.limit+1, req.offset)
+	return sb.String(), args
+}
+
+// newResourceMux returns the routes of the resource API.
+func (e *DataSourceHandler) newResourceMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	for name, object := range catalogObjects {
+		mux.HandleFunc("/"+name, e.catalogHandler(name, object))
+	}
+	mux.HandleFunc("/calculation-views/parameters", e.calcViewParametersHandler)
+	mux.HandleFunc("/calculation-views/semantics", e.calcViewSemanticsHandler)
+	mux.HandleFunc("/validate", e.validateHandler)
+	return mux
+}
+
+// CallResource serves the resource API of the data source.
+func (e *DataSourceHandler) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return e.resourceHandler.CallResource(ctx, req, sender)
+}
+
+// catalogHandler lists the catalog objects. Responses are cached per data source and
+// request parameters.
+func (e *DataSourceHandler) catalogHandler(name string, object catalogObject) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeResourceError(rw, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		values := r.URL.Query()
+		req, err := parseCatalogRequest(values, e.dsInfo.JsonData.DefaultSchema)
+		if err != nil {
+			writeResourceError(rw, http.StatusBadRequest, err)
+			return
+		}
+		if object.tableColumn != "" && req.table == "" {
+			writeResourceError(rw, http.StatusBadRequest, fmt.Errorf("the table parameter is required"))
+			return
+		}
+
+		// url.Values.Encode sorts the parameters, so equal requests share an entry
+		key := name + "?" + values.Encode()
+		if body, ok := e.metadataCache.get(key); ok {
+			writeResourceJSON(rw, body)
+			return
+		}
+
+		res, err := e.listCatalog(r.Context(), object, req)
+		if err != nil {
+			e.log.Error("Catalog query failed", "resource", name, "error", err)
+			writeResourceError(rw, http.StatusInternalServerError, e.TransformQueryError(e.log, err))
+			return
+		}
+		body, err := json.Marshal(res)
+		if err != nil {
+			writeResourceError(rw, http.StatusInternalServerError, err)
+			return
+		}
+		e.metadataCache.set(key, body)
+		writeResourceJSON(rw, body)
+	}
+}
+
+// listCatalog reads a page of catalog objects.
+func (e *DataSourceHandler) listCatalog(ctx context.Context, object catalogObject, req catalogRequest) (*catalogResponse, error) {
+	query, args := object.query(req)
+	rows, err := e.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			e.log.Warn("Failed to close rows", "err", err)
+		}
+	}()
+
+	res := &catalogResponse{Schema: req.schema, Items: []map[string]any{}, Limit: req.limit, Offset: req.offset}
+	values := make([]any, len(object.columns))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(res.Items) == req.limit {
+			res.HasMore = true
+			break
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		item := make(map[string]any, len(values))
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			item[object.keys[i]] = v
+		}
+		res.Items = append(res.Items, item)
+	}
+	return res, rows.Err()
+}
+
+func writeResourceJSON(rw http.ResponseWriter, body []byte) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(body)
+}
+
+func writeResourceError(rw http.ResponseWriter, status int, err error) {
+	body, _ := json.Marshal(map[string]string{"message": err.Error()})
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_, _ = rw.Write(body)
+}
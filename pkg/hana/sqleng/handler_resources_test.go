@@ -0,0 +1,89 @@
+package sqleng
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+func TestCatalogQuery(t *testing.T) {
+	req, err := parseCatalogRequest(url.Values{"filter": {"sales_*"}, "limit": {"50"}, "offset": {"100"}}, "APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args := catalogObjects["tables"].query(req)
+	want := `SELECT TABLE_NAME, TABLE_TYPE, COMMENTS FROM SYS.TABLES WHERE IS_SYSTEM_TABLE = 'FALSE' AND SCHEMA_NAME = ? AND UPPER(TABLE_NAME) LIKE ? ESCAPE '\' ORDER BY TABLE_NAME LIMIT 51 OFFSET 100`
+	if query != want {
+		t.Fatalf("got  %q\nwant %q", query, want)
+	}
+	if !reflect.DeepEqual(args, []any{"APP", `SALES\_%`}) {
+		t.Fatalf("unexpected arguments %v", args)
+	}
+
+	// without a default schema the current schema of the connection is listed
+	req, err = parseCatalogRequest(url.Values{"table": {"ORDERS"}, "filter": {"qty"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args = catalogObjects["columns"].query(req)
+	if want := `WHERE SCHEMA_NAME = CURRENT_SCHEMA AND TABLE_NAME = ? AND UPPER(COLUMN_NAME) LIKE ? ESCAPE '\' ORDER BY POSITION LIMIT 1001 OFFSET 0`; query[len(query)-len(want):] != want {
+		t.Fatalf("got %q, want suffix %q", query, want)
+	}
+	if !reflect.DeepEqual(args, []any{"ORDERS", "%QTY%"}) {
+		t.Fatalf("unexpected arguments %v", args)
+	}
+
+	for _, values := range []url.Values{{"limit": {"0"}}, {"limit": {"100000"}}, {"offset": {"-1"}}, {"offset": {"x"}}} {
+		if _, err := parseCatalogRequest(values, ""); err == nil {
+			t.Fatalf("expected an error for %v", values)
+		}
+	}
+}
+
+func TestMetadataCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newMetadataCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.set("tables?schema=APP", []byte("[]"))
+	if body, ok := c.get("tables?schema=APP"); !ok || string(body) != "[]" {
+		t.Fatalf("expected a cached entry, got %q %v", body, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, ok := c.get("tables?schema=APP"); ok {
+		t.Fatal("expected the entry to expire")
+	}
+
+	disabled := newMetadataCache(0)
+	disabled.set("schemas?", []byte("[]"))
+	if _, ok := disabled.get("schemas?"); ok {
+		t.Fatal("expected no caching without ttl")
+	}
+}
+
+func TestCallResourceBadRequest(t *testing.T) {
+	handler, err := NewQueryDataHandler("error", nil, DataPluginConfiguration{}, nil, nil, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"columns?schema=APP", "tables?limit=abc"} {
+		var res *backend.CallResourceResponse
+		err := handler.CallResource(context.Background(), &backend.CallResourceRequest{Method: http.MethodGet, Path: path, URL: path},
+			backend.CallResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+				res = r
+				return nil
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res == nil || res.Status != http.StatusBadRequest {
+			t.Fatalf("%s: expected a bad request, got %+v", path, res)
+		}
+	}
+}
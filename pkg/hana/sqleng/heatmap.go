@@ -0,0 +1,138 @@
+package sqleng
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// frameTypeHeatmapCells is the frame type of pre-bucketed heatmap data in Grafana.
+const frameTypeHeatmapCells data.FrameType = "heatmap-cells"
+
+// Column names recognised by the heatmap format, compared case-insensitively.
+var (
+	heatmapBucketColumnNames = []string{"bucket_le", "le", "bucket", "ymax"}
+	heatmapCountColumnNames  = []string{"count", "value", "cnt"}
+)
+
+// bucketBound returns the upper bound of a bucket, which may be a number or a string like "+Inf".
+func bucketBound(f *data.Field, i int) (float64, bool, error) {
+	v, ok := f.ConcreteAt(i)
+	if !ok {
+		return 0, false, nil
+	}
+	if s, ok := v.(string); ok {
+		b, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid bucket bound %q", s)
+		}
+		return b, true, nil
+	}
+	b, err := f.NullableFloatAt(i)
+	if err != nil || b == nil {
+		return 0, false, err
+	}
+	return *b, true, nil
+}
+
+// convertToHeatmapFrame turns long rows of (time, bucket_le, count) into the dense
+// heatmap-cells layout: one row per time and bucket, ordered by time and bucket, with
+// missing buckets counted as zero. Counts of duplicate cells are summed.
+func convertToHeatmapFrame(frame *data.Frame, qm *dataQueryModel) (*data.Frame, error) {
+	timeIdx := qm.timeIndex
+	if timeIdx == -1 {
+		for i, f := range frame.Fields {
+			if t := f.Type(); t == data.FieldTypeTime || t == data.FieldTypeNullableTime {
+				timeIdx = i
+				break
+			}
+		}
+	}
+	if timeIdx == -1 {
+		return nil, errors.New("time column is missing; make sure your data includes a time column for heatmap format")
+	}
+	bucketIdx := findFieldByName(frame, heatmapBucketColumnNames, nil)
+	if bucketIdx == -1 {
+		return nil, errors.New("bucket column is missing; make sure your data includes the bucket upper bound as column bucket_le")
+	}
+	countIdx := findFieldByName(frame, heatmapCountColumnNames, nil)
+	if countIdx == -1 {
+		for i, f := range frame.Fields {
+			if i != timeIdx && i != bucketIdx && isValueField(f) {
+				countIdx = i
+				break
+			}
+		}
+	}
+	if countIdx == -1 {
+		return nil, errors.New("count column is missing; make sure your data includes a numeric column named count")
+	}
+
+	cells := map[time.Time]map[float64]float64{}
+	bounds := map[float64]bool{}
+	for i := 0; i < frame.Rows(); i++ {
+		t, ok := frame.Fields[timeIdx].ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		le, ok, err := bucketBound(frame.Fields[bucketIdx], i)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		count, err := frame.Fields[countIdx].NullableFloatAt(i)
+		if err != nil {
+			return nil, fmt.Errorf("invalid count: %w", err)
+		}
+		x := t.(time.Time)
+		if cells[x] == nil {
+			cells[x] = map[float64]float64{}
+		}
+		if count != nil {
+			cells[x][le] += *count
+		}
+		bounds[le] = true
+	}
+
+	times := make([]time.Time, 0, len(cells))
+	for t := range cells {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	les := make([]float64, 0, len(bounds))
+	for le := range bounds {
+		les = append(les, le)
+	}
+	sort.Float64s(les)
+
+	size := len(times) * len(les)
+	xs := make([]time.Time, 0, size)
+	ys := make([]float64, 0, size)
+	counts := make([]float64, 0, size)
+	for _, t := range times {
+		for _, le := range les {
+			xs = append(xs, t)
+			ys = append(ys, le)
+			counts = append(counts, cells[t][le])
+		}
+	}
+
+	heatmap := data.NewFrame(frame.Name,
+		data.NewField("xMax", nil, xs),
+		data.NewField("yMax", nil, ys),
+		data.NewField("count", nil, counts),
+	)
+	heatmap.Meta = frame.Meta
+	if heatmap.Meta == nil {
+		heatmap.Meta = &data.FrameMeta{}
+	}
+	heatmap.Meta.Type = frameTypeHeatmapCells
+	return heatmap, nil
+}
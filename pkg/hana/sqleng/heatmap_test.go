@@ -0,0 +1,49 @@
+package sqleng
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestConvertToHeatmapFrame(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	frame := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{t1, t0, t0, t0, t1}),
+		data.NewField("BUCKET_LE", nil, []string{"10", "+Inf", "10", "10", "100"}),
+		data.NewField("COUNT", nil, []int64{4, 1, 2, 3, 5}),
+	)
+	heatmap, err := convertToHeatmapFrame(frame, &dataQueryModel{timeIndex: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if heatmap.Meta.Type != frameTypeHeatmapCells {
+		t.Fatalf("unexpected frame type %q", heatmap.Meta.Type)
+	}
+	if heatmap.Rows() != 6 {
+		t.Fatalf("got %d rows, want 6", heatmap.Rows())
+	}
+	want := []struct {
+		x     time.Time
+		y     float64
+		count float64
+	}{
+		{t0, 10, 5}, {t0, 100, 0}, {t0, math.Inf(1), 1},
+		{t1, 10, 4}, {t1, 100, 5}, {t1, math.Inf(1), 0},
+	}
+	for i, w := range want {
+		x := heatmap.Fields[0].At(i).(time.Time)
+		y := heatmap.Fields[1].At(i).(float64)
+		count := heatmap.Fields[2].At(i).(float64)
+		if !x.Equal(w.x) || y != w.y || count != w.count {
+			t.Fatalf("row %d is (%v, %v, %v), want %v", i, x, y, count, w)
+		}
+	}
+
+	if _, err := convertToHeatmapFrame(data.NewFrame("", data.NewField("time", nil, []time.Time{t0})), &dataQueryModel{timeIndex: 0}); err == nil {
+		t.Fatal("expected an error without bucket column")
+	}
+}
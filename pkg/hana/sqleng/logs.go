@@ -0,0 +1,254 @@
+package sqleng
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Column names recognised by the logs format. HANA returns unquoted aliases in upper
+// case, so all names are compared case-insensitively.
+var (
+	logTimeColumnNames     = []string{"time", "timestamp", "ts", "time_sec"}
+	logBodyColumnNames     = []string{"body", "message", "msg", "line", "log", "content", "text"}
+	logSeverityColumnNames = []string{"severity", "level", "log_level", "loglevel", "lvl"}
+	logIDColumnNames       = []string{"id", "log_id", "uuid"}
+)
+
+// unknownLogLevel is used by the logs volume for rows without a severity.
+const unknownLogLevel = "unknown"
+
+// logsColumns holds the indices of the detected log columns, -1 if not present.
+type logsColumns struct {
+	time     int
+	body     int
+	severity int
+	id       int
+}
+
+func isStringField(f *data.Field) bool {
+	t := f.Type()
+	return t == data.FieldTypeString || t == data.FieldTypeNullableString
+}
+
+func findFieldByName(frame *data.Frame, names []string, accept func(*data.Field) bool) int {
+	for _, name := range names {
+		for i, f := range frame.Fields {
+			if strings.EqualFold(f.Name, name) && (accept == nil || accept(f)) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// detectLogsColumns finds the time, body, severity and id columns of a log result.
+func detectLogsColumns(frame *data.Frame, qm *dataQueryModel) (logsColumns, error) {
+	cols := logsColumns{
+		time:     qm.timeIndex,
+		body:     findFieldByName(frame, logBodyColumnNames, isStringField),
+		severity: findFieldByName(frame, logSeverityColumnNames, nil),
+		id:       findFieldByName(frame, logIDColumnNames, nil),
+	}
+	if cols.time == -1 {
+		cols.time = findFieldByName(frame, logTimeColumnNames, nil)
+	}
+	if cols.time == -1 {
+		for i, f := range frame.Fields {
+			if t := f.Type(); t == data.FieldTypeTime || t == data.FieldTypeNullableTime {
+				cols.time = i
+				break
+			}
+		}
+	}
+	if cols.time == -1 {
+		return cols, errors.New("time column is missing; make sure your data includes a time column for logs format")
+	}
+	if err := convertSQLTimeColumnToEpochMS(frame, cols.time); err != nil {
+		return cols, fmt.Errorf("%v: %w", "failed to convert time column", err)
+	}
+
+	if cols.body == -1 {
+		for i, f := range frame.Fields {
+			if isStringField(f) && i != cols.severity && i != cols.id {
+				cols.body = i
+				break
+			}
+		}
+	}
+	if cols.body == -1 {
+		return cols, errors.New("log message column is missing; make sure your data includes a string column named body or message")
+	}
+	return cols, nil
+}
+
+// stringAt returns the value of a field as string, converting non string values.
+func stringAt(f *data.Field, i int) (string, bool) {
+	v, ok := f.ConcreteAt(i)
+	if !ok {
+		return "", false
+	}
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	return fmt.Sprint(v), true
+}
+
+// convertToLogsFrame reshapes a result into the log-lines frame of the data plane contract:
+// a timestamp, the log body, an optional severity and id, and the remaining string columns
+// as labels. Other columns are kept and shown as log details.
+func convertToLogsFrame(frame *data.Frame, qm *dataQueryModel) (*data.Frame, error) {
+	cols, err := detectLogsColumns(frame, qm)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := frame.Rows()
+	timestamps := make([]time.Time, rows)
+	bodies := make([]string, rows)
+	labels := make([]json.RawMessage, rows)
+	var severities, ids []string
+	if cols.severity != -1 {
+		severities = make([]string, rows)
+	}
+	if cols.id != -1 {
+		ids = make([]string, rows)
+	}
+
+	var labelFields, extraFields []*data.Field
+	for i, f := range frame.Fields {
+		if i == cols.time || i == cols.body || i == cols.severity || i == cols.id {
+			continue
+		}
+		if isStringField(f) {
+			labelFields = append(labelFields, f)
+		} else {
+			extraFields = append(extraFields, f)
+		}
+	}
+
+	for i := 0; i < rows; i++ {
+		if ts, ok := frame.Fields[cols.time].ConcreteAt(i); ok {
+			timestamps[i] = ts.(time.Time)
+		}
+		bodies[i], _ = stringAt(frame.Fields[cols.body], i)
+		if severities != nil {
+			severities[i], _ = stringAt(frame.Fields[cols.severity], i)
+		}
+		if ids != nil {
+			ids[i], _ = stringAt(frame.Fields[cols.id], i)
+		}
+		rowLabels := make(map[string]string, len(labelFields))
+		for _, f := range labelFields {
+			if s, ok := stringAt(f, i); ok {
+				rowLabels[f.Name] = s
+			}
+		}
+		if labels[i], err = json.Marshal(rowLabels); err != nil {
+			return nil, err
+		}
+	}
+
+	logs := data.NewFrame(frame.Name,
+		data.NewField("timestamp", nil, timestamps),
+		data.NewField("body", nil, bodies),
+	)
+	if severities != nil {
+		logs.Fields = append(logs.Fields, data.NewField("severity", nil, severities))
+	}
+	if ids != nil {
+		logs.Fields = append(logs.Fields, data.NewField("id", nil, ids))
+	}
+	logs.Fields = append(logs.Fields, data.NewField("labels", nil, labels))
+	logs.Fields = append(logs.Fields, extraFields...)
+
+	logs.Meta = frame.Meta
+	if logs.Meta == nil {
+		logs.Meta = &data.FrameMeta{}
+	}
+	logs.Meta.Type = data.FrameTypeLogLines
+	logs.Meta.TypeVersion = data.FrameTypeVersion{0, 0}
+	logs.Meta.PreferredVisualization = data.VisTypeLogs
+	return logs, nil
+}
+
+// logsVolumeFrames counts the log rows per interval and severity. It returns one time
+// series frame per severity, as expected by the logs volume histogram in Explore.
+func logsVolumeFrames(frame *data.Frame, qm *dataQueryModel, interval time.Duration) (data.Frames, error) {
+	cols, err := detectLogsColumns(frame, qm)
+	if err != nil {
+		return nil, err
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	from := qm.TimeRange.From.Truncate(interval)
+	buckets := int(qm.TimeRange.To.Sub(from)/interval) + 1
+	counts := map[string][]float64{}
+	for i := 0; i < frame.Rows(); i++ {
+		v, ok := frame.Fields[cols.time].ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		bucket := int(v.(time.Time).Sub(from) / interval)
+		if bucket < 0 || bucket >= buckets {
+			continue
+		}
+		level := unknownLogLevel
+		if cols.severity != -1 {
+			if s, ok := stringAt(frame.Fields[cols.severity], i); ok && s != "" {
+				level = strings.ToLower(s)
+			}
+		}
+		if counts[level] == nil {
+			counts[level] = make([]float64, buckets)
+		}
+		counts[level][bucket]++
+	}
+
+	levels := make([]string, 0, len(counts))
+	for level := range counts {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+
+	times := make([]time.Time, buckets)
+	for i := range times {
+		times[i] = from.Add(time.Duration(i) * interval)
+	}
+
+	frames := make(data.Frames, 0, len(levels))
+	for _, level := range levels {
+		valueField := data.NewField("Value", data.Labels{"level": level}, counts[level])
+		valueField.SetConfig(&data.FieldConfig{DisplayNameFromDS: level})
+		f := data.NewFrame(level, data.NewField(data.TimeSeriesTimeFieldName, nil, times), valueField)
+		f.Meta = &data.FrameMeta{
+			Type:        data.FrameTypeTimeSeriesMulti,
+			TypeVersion: data.FrameTypeVersion{0, 1},
+		}
+		frames = append(frames, f)
+	}
+	if len(frames) > 0 {
+		frames[0].Meta.ExecutedQueryString = qm.InterpolatedQuery
+		// the volume is counted from the fetched rows, so a limited result undercounts it
+		if frame.Meta != nil && len(frame.Meta.Notices) > 0 {
+			frames[0].Meta.Notices = append(frames[0].Meta.Notices, frame.Meta.Notices...)
+			for _, n := range frame.Meta.Notices {
+				if n.Severity == data.NoticeSeverityWarning {
+					frames[0].AppendNotices(data.Notice{
+						Severity: data.NoticeSeverityWarning,
+						Text:     "The logs volume only counts the fetched rows and is incomplete",
+					})
+					break
+				}
+			}
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,90 @@
+package sqleng
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func testLogsResult() (*data.Frame, *dataQueryModel) {
+	t0 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	frame := data.NewFrame("",
+		data.NewField("TS", nil, []time.Time{t0, t0.Add(30 * time.Second), t0.Add(90 * time.Second)}),
+		data.NewField("LEVEL", nil, []string{"ERROR", "info", "ERROR"}),
+		data.NewField("MESSAGE", nil, []string{"disk full", "started", "disk full"}),
+		data.NewField("HOST", nil, []*string{strPtr("a"), nil, strPtr("b")}),
+		data.NewField("DURATION", nil, []int64{1, 2, 3}),
+	)
+	qm := &dataQueryModel{
+		timeIndex: -1,
+		TimeRange: backend.TimeRange{From: t0, To: t0.Add(2 * time.Minute)},
+	}
+	return frame, qm
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestConvertToLogsFrame(t *testing.T) {
+	frame, qm := testLogsResult()
+	logs, err := convertToLogsFrame(frame, qm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs.Meta.Type != data.FrameTypeLogLines || logs.Meta.PreferredVisualization != data.VisTypeLogs {
+		t.Fatalf("unexpected meta %+v", logs.Meta)
+	}
+	names := []string{}
+	for _, f := range logs.Fields {
+		names = append(names, f.Name)
+	}
+	want := []string{"timestamp", "body", "severity", "labels", "DURATION"}
+	if len(names) != len(want) {
+		t.Fatalf("got fields %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got fields %v, want %v", names, want)
+		}
+	}
+	labels := map[string]string{}
+	if err := json.Unmarshal(logs.Fields[3].At(0).(json.RawMessage), &labels); err != nil {
+		t.Fatal(err)
+	}
+	if labels["HOST"] != "a" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+}
+
+func TestLogsVolumeFrames(t *testing.T) {
+	frame, qm := testLogsResult()
+	frames, err := logsVolumeFrames(frame, qm, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expected one frame per level, got %d", len(frames))
+	}
+	errors := frames[0].Fields[1]
+	if errors.Labels["level"] != "error" {
+		t.Fatalf("unexpected labels %v", errors.Labels)
+	}
+	if errors.Len() != 3 || errors.At(0).(float64) != 1 || errors.At(1).(float64) != 1 {
+		t.Fatalf("unexpected counts %v %v %v", errors.At(0), errors.At(1), errors.At(2))
+	}
+}
+
+func TestLogsVolumeFramesLimited(t *testing.T) {
+	frame, qm := testLogsResult()
+	frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: "Results have been limited to 3 because the SQL row limit was reached"})
+	frames, err := logsVolumeFrames(frame, qm, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notices := frames[0].Meta.Notices
+	if len(notices) != 2 || notices[0].Text != frame.Meta.Notices[0].Text {
+		t.Fatalf("unexpected notices %+v", notices)
+	}
+}
@@ -0,0 +1,66 @@
+package sqleng
+
+import (
+	"sync"
+	"time"
+)
+
+// maxMetadataCacheEntries bounds the number of cached responses, expired entries are
+// dropped when it is reached.
+const maxMetadataCacheEntries = 1000
+
+type metadataCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// metadataCache keeps catalog responses in memory for a fixed time. A ttl of zero
+// disables caching.
+type metadataCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]metadataCacheEntry
+	now     func() time.Time
+}
+
+func newMetadataCache(ttl time.Duration) *metadataCache {
+	return &metadataCache{ttl: ttl, entries: map[string]metadataCacheEntry{}, now: time.Now}
+}
+
+func (c *metadataCache) get(key string) ([]byte, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (c *metadataCache) set(key string, body []byte) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= maxMetadataCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// all entries are alive, start over rather than growing without limit
+		if len(c.entries) >= maxMetadataCacheEntries {
+			c.entries = map[string]metadataCacheEntry{}
+		}
+	}
+	c.entries[key] = metadataCacheEntry{body: body, expires: now.Add(c.ttl)}
+}
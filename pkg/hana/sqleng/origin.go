@@ -0,0 +1,44 @@
+package sqleng
+
+import "github.com/grafana/grafana-plugin-sdk-go/backend"
+
+// dashboardUIDHeader and panelIDHeader are sent by Grafana with the queries of dashboard panels.
+const (
+	dashboardUIDHeader = "X-Dashboard-Uid"
+	panelIDHeader      = "X-Panel-Id"
+)
+
+// queryOrigin describes who sent the queries of a request. User is the login of the
+// Grafana user, role the role of the user in the organization.
+type queryOrigin struct {
+	fromAlert    bool
+	dashboardUID string
+	panelID      string
+	user         string
+	email        string
+	role         string
+}
+
+// requestOrigin returns the origin of the queries of a request.
+func requestOrigin(req *backend.QueryDataRequest) queryOrigin {
+	origin := userOrigin(req.PluginContext.User)
+	origin.fromAlert = isAlertRequest(req)
+	origin.dashboardUID = requestHeader(req, dashboardUIDHeader)
+	origin.panelID = requestHeader(req, panelIDHeader)
+	return origin
+}
+
+// userOrigin returns the origin of a request of a user, who is nil for requests of Grafana.
+func userOrigin(user *backend.User) queryOrigin {
+	if user == nil {
+		return queryOrigin{}
+	}
+	return queryOrigin{user: user.Login, email: user.Email, role: user.Role}
+}
+
+func requestHeader(req *backend.QueryDataRequest, name string) string {
+	if v := req.Headers[name]; v != "" {
+		return v
+	}
+	return req.GetHTTPHeader(name)
+}
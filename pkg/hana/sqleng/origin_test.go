@@ -0,0 +1,18 @@
+package sqleng
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestRequestOrigin(t *testing.T) {
+	req := &backend.QueryDataRequest{
+		Headers:       map[string]string{"http_" + dashboardUIDHeader: "sales", fromAlertHeader: "true"},
+		PluginContext: backend.PluginContext{User: &backend.User{Login: "viewer", Role: "Viewer"}},
+	}
+	origin := requestOrigin(req)
+	if origin != (queryOrigin{fromAlert: true, dashboardUID: "sales", user: "viewer", role: "Viewer"}) {
+		t.Fatalf("got %+v", origin)
+	}
+}
@@ -0,0 +1,274 @@
+package sqleng
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// defaultPivotMaxColumns is the number of pivot columns generated when the query sets no cap.
+	defaultPivotMaxColumns = 100
+	// maxPivotColumns is the upper limit of the cap a query may set.
+	maxPivotColumns = 1000
+	// pivotNullColumn is the name of the column of rows without pivot value. It is kept
+	// apart from a column of the string value null.
+	pivotNullColumn = "null"
+)
+
+// PivotOptions turn a table result into a crosstab: the distinct values of Column become
+// columns holding the aggregated Value for every combination of the Rows columns.
+type PivotOptions struct {
+	// Rows are the row key columns. All columns except Column and Value if empty.
+	Rows   []string `json:"rows"`
+	Column string   `json:"column"`
+	Value  string   `json:"value"`
+	// Aggregate combines the values of the same cell: sum (default), avg, min, max, count, first or last.
+	Aggregate string `json:"aggregate"`
+	// MaxColumns caps the number of generated columns, defaultPivotMaxColumns if 0.
+	MaxColumns int `json:"maxColumns"`
+}
+
+var pivotAggregates = map[string]bool{
+	"": true, "sum": true, "avg": true, "min": true, "max": true, "count": true, "first": true, "last": true,
+}
+
+func (p *PivotOptions) validate() error {
+	if p.Column == "" || p.Value == "" {
+		return fmt.Errorf("pivot needs a pivot column and a value column")
+	}
+	if !pivotAggregates[strings.ToLower(p.Aggregate)] {
+		return fmt.Errorf("unknown pivot aggregate %q", p.Aggregate)
+	}
+	if p.MaxColumns < 0 || p.MaxColumns > maxPivotColumns {
+		return fmt.Errorf("pivot column cap must not exceed %d", maxPivotColumns)
+	}
+	return nil
+}
+
+// pivotCell accumulates the values of one cell.
+type pivotCell struct {
+	value *float64
+	sum   float64
+	count int
+}
+
+func (c *pivotCell) add(aggregate string, v *float64) {
+	if aggregate == "count" {
+		c.count++
+		return
+	}
+	if v == nil {
+		return
+	}
+	c.count++
+	c.sum += *v
+	switch {
+	case c.value == nil:
+		c.value = v
+	case aggregate == "min" && *v < *c.value, aggregate == "max" && *v > *c.value, aggregate == "last":
+		c.value = v
+	}
+}
+
+func (c *pivotCell) result(aggregate string) *float64 {
+	var r float64
+	switch aggregate {
+	case "count":
+		r = float64(c.count)
+	case "", "sum":
+		if c.count == 0 {
+			return nil
+		}
+		r = c.sum
+	case "avg":
+		if c.count == 0 {
+			return nil
+		}
+		r = c.sum / float64(c.count)
+	default:
+		return c.value
+	}
+	return &r
+}
+
+// pivotKeyID identifies a distinct value of the pivot column, null apart from all strings.
+type pivotKeyID struct {
+	name string
+	null bool
+}
+
+// pivotKey is a distinct value of the pivot column together with its sort key.
+type pivotKey struct {
+	pivotKeyID
+	num  *float64
+	time *time.Time
+}
+
+// rank orders the kinds of keys: times, numbers, strings and null last.
+func (k *pivotKey) rank() int {
+	switch {
+	case k.null:
+		return 3
+	case k.time != nil:
+		return 0
+	case k.num != nil:
+		return 1
+	}
+	return 2
+}
+
+// lessPivotKey is a total order of the keys, so the columns are sorted the same way on
+// every run. Keys of a kind are sorted by value, then by name.
+func lessPivotKey(a, b *pivotKey) bool {
+	if ra, rb := a.rank(), b.rank(); ra != rb {
+		return ra < rb
+	}
+	c := 0
+	switch {
+	case a.time != nil:
+		c = a.time.Compare(*b.time)
+	case a.num != nil:
+		c = cmp.Compare(*a.num, *b.num)
+	}
+	if c != 0 {
+		return c < 0
+	}
+	return a.name < b.name
+}
+
+// pivotFrame reshapes a table frame as described by the pivot options. Rows keep the order
+// of their first appearance in the result, pivot columns are sorted by their value.
+func pivotFrame(frame *data.Frame, p *PivotOptions) (*data.Frame, error) {
+	aggregate := strings.ToLower(p.Aggregate)
+	colIdx := findFieldByName(frame, []string{p.Column}, nil)
+	if colIdx == -1 {
+		return nil, fmt.Errorf("pivot column %q not found in result", p.Column)
+	}
+	valIdx := findFieldByName(frame, []string{p.Value}, nil)
+	if valIdx == -1 {
+		return nil, fmt.Errorf("pivot value column %q not found in result", p.Value)
+	}
+	if aggregate != "count" && !isValueField(frame.Fields[valIdx]) {
+		return nil, fmt.Errorf("pivot value column %q is not numeric, use the count aggregate", p.Value)
+	}
+
+	var rowIdx []int
+	if len(p.Rows) == 0 {
+		for i := range frame.Fields {
+			if i != colIdx && i != valIdx {
+				rowIdx = append(rowIdx, i)
+			}
+		}
+	} else {
+		for _, name := range p.Rows {
+			i := findFieldByName(frame, []string{name}, nil)
+			if i == -1 {
+				return nil, fmt.Errorf("pivot row column %q not found in result", name)
+			}
+			rowIdx = append(rowIdx, i)
+		}
+	}
+
+	keys := map[pivotKeyID]*pivotKey{}
+	groups := map[string]int{}
+	var groupRows []int
+	var cells []map[pivotKeyID]*pivotCell
+	pivotField, valueField := frame.Fields[colIdx], frame.Fields[valIdx]
+	for i := 0; i < frame.Rows(); i++ {
+		var sb strings.Builder
+		for _, ri := range rowIdx {
+			s, ok := stringAt(frame.Fields[ri], i)
+			fmt.Fprintf(&sb, "%t:%d:%s|", ok, len(s), s)
+		}
+		g, ok := groups[sb.String()]
+		if !ok {
+			g = len(groupRows)
+			groups[sb.String()] = g
+			groupRows = append(groupRows, i)
+			cells = append(cells, map[pivotKeyID]*pivotCell{})
+		}
+
+		var id pivotKeyID
+		if name, ok := stringAt(pivotField, i); ok {
+			id.name = name
+		} else {
+			id = pivotKeyID{name: pivotNullColumn, null: true}
+		}
+		if _, seen := keys[id]; !seen {
+			key := &pivotKey{pivotKeyID: id}
+			if v, ok := pivotField.ConcreteAt(i); ok {
+				if t, isTime := v.(time.Time); isTime {
+					key.time = &t
+				} else if isValueField(pivotField) {
+					key.num, _ = pivotField.NullableFloatAt(i)
+				}
+			}
+			keys[id] = key
+		}
+
+		cell := cells[g][id]
+		if cell == nil {
+			cell = &pivotCell{}
+			cells[g][id] = cell
+		}
+		var v *float64
+		if aggregate != "count" {
+			var err error
+			if v, err = valueField.NullableFloatAt(i); err != nil {
+				return nil, err
+			}
+		}
+		cell.add(aggregate, v)
+	}
+
+	sorted := make([]*pivotKey, 0, len(keys))
+	for _, k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return lessPivotKey(sorted[i], sorted[j]) })
+
+	maxColumns := p.MaxColumns
+	if maxColumns == 0 {
+		maxColumns = defaultPivotMaxColumns
+	}
+	dropped := 0
+	if len(sorted) > maxColumns {
+		dropped = len(sorted) - maxColumns
+		sorted = sorted[:maxColumns]
+	}
+
+	pivot := data.NewFrame(frame.Name)
+	for _, ri := range rowIdx {
+		origin := frame.Fields[ri]
+		f := data.NewFieldFromFieldType(origin.Type(), len(groupRows))
+		f.Name = origin.Name
+		f.Config = origin.Config
+		for g, row := range groupRows {
+			f.Set(g, origin.At(row))
+		}
+		pivot.Fields = append(pivot.Fields, f)
+	}
+	for _, k := range sorted {
+		values := make([]*float64, len(groupRows))
+		for g := range groupRows {
+			if cell := cells[g][k.pivotKeyID]; cell != nil {
+				values[g] = cell.result(aggregate)
+			}
+		}
+		pivot.Fields = append(pivot.Fields, data.NewField(k.name, nil, values))
+	}
+
+	pivot.Meta = frame.Meta
+	if dropped > 0 {
+		pivot.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("pivot is limited to %d columns, %d columns were dropped", maxColumns, dropped),
+		})
+	}
+	return pivot, nil
+}
@@ -0,0 +1,112 @@
+package sqleng
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func testPivotResult() *data.Frame {
+	return data.NewFrame("",
+		data.NewField("MATERIAL", nil, []string{"M2", "M1", "M2", "M1", "M2"}),
+		data.NewField("MONTH", nil, []int64{10, 9, 9, 10, 10}),
+		data.NewField("QUANTITY", nil, []*float64{fp(1), fp(2), fp(3), nil, fp(5)}),
+	)
+}
+
+func fp(f float64) *float64 { return &f }
+
+func sp(s string) *string { return &s }
+
+func TestPivotFrame(t *testing.T) {
+	pivot, err := pivotFrame(testPivotResult(), &PivotOptions{Rows: []string{"material"}, Column: "month", Value: "quantity"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, f := range pivot.Fields {
+		names = append(names, f.Name)
+	}
+	want := []string{"MATERIAL", "9", "10"}
+	if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Fatalf("got columns %v, want %v", names, want)
+	}
+	if m := pivot.Fields[0].At(0).(string); m != "M2" {
+		t.Fatalf("rows not in order of appearance, first is %q", m)
+	}
+	// M2/10 sums 1 and 5, M1/10 only has a null value
+	if v := pivot.Fields[2].At(0).(*float64); v == nil || *v != 6 {
+		t.Fatalf("unexpected sum %v", v)
+	}
+	if v := pivot.Fields[2].At(1).(*float64); v != nil {
+		t.Fatalf("expected null cell, got %v", *v)
+	}
+
+	pivot, err = pivotFrame(testPivotResult(), &PivotOptions{Column: "MONTH", Value: "QUANTITY", Aggregate: "count", MaxColumns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pivot.Fields) != 2 || pivot.Fields[1].Name != "9" {
+		t.Fatalf("unexpected capped fields %v", pivot.Fields)
+	}
+	if v := pivot.Fields[1].At(0).(*float64); v == nil || *v != 1 {
+		t.Fatalf("unexpected count %v", v)
+	}
+	if len(pivot.Meta.Notices) != 1 {
+		t.Fatalf("expected a notice for the dropped column, got %v", pivot.Meta.Notices)
+	}
+
+	if _, err := pivotFrame(testPivotResult(), &PivotOptions{Column: "MONTH", Value: "MATERIAL"}); err == nil {
+		t.Fatal("expected an error for a non numeric value column")
+	}
+}
+
+func TestPivotFrameNullColumn(t *testing.T) {
+	frame := data.NewFrame("",
+		data.NewField("STATUS", nil, []*string{sp("null"), nil, sp("open"), nil}),
+		data.NewField("QUANTITY", nil, []*float64{fp(1), fp(2), fp(3), fp(4)}),
+	)
+	pivot, err := pivotFrame(frame, &PivotOptions{Column: "STATUS", Value: "QUANTITY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the string null and the missing values stay apart, missing values sort last
+	if len(pivot.Fields) != 3 || pivot.Fields[0].Name != "null" || pivot.Fields[1].Name != "open" || pivot.Fields[2].Name != pivotNullColumn {
+		t.Fatalf("unexpected fields %v", pivot.Fields)
+	}
+	if v := pivot.Fields[0].At(0).(*float64); v == nil || *v != 1 {
+		t.Fatalf("unexpected value of the string null %v", v)
+	}
+	if v := pivot.Fields[2].At(0).(*float64); v == nil || *v != 6 {
+		t.Fatalf("unexpected value of the missing values %v", v)
+	}
+
+	now := time.Now()
+	keys := []*pivotKey{
+		{pivotKeyID: pivotKeyID{name: "b"}},
+		{pivotKeyID: pivotKeyID{name: "null", null: true}},
+		{pivotKeyID: pivotKeyID{name: "2"}, num: fp(2)},
+		{pivotKeyID: pivotKeyID{name: "t"}, time: &now},
+		{pivotKeyID: pivotKeyID{name: "a"}},
+	}
+	for i, a := range keys {
+		for j, b := range keys {
+			if i != j && lessPivotKey(a, b) == lessPivotKey(b, a) {
+				t.Fatalf("keys %s and %s are not ordered", a.name, b.name)
+			}
+		}
+	}
+}
+
+func TestPivotValidation(t *testing.T) {
+	if _, err := ParseQueryJson([]byte(`{"rawSql":"select 1","format":"time_series","pivot":{"column":"A","value":"B"}}`)); err == nil {
+		t.Fatal("expected an error for pivot on time series")
+	}
+	if _, err := ParseQueryJson([]byte(`{"rawSql":"select 1","format":"table","pivot":{"column":"A","value":"B","aggregate":"median"}}`)); err == nil {
+		t.Fatal("expected an error for an unknown aggregate")
+	}
+	if _, err := ParseQueryJson([]byte(`{"rawSql":"select 1","format":"table","pivot":{"column":"A","value":"B","aggregate":"avg"}}`)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,153 @@
+package sqleng
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// QueryModelVersion is the version of the query model understood by this plugin.
+// Queries without a version are migrated from the older shapes by migrateQueryJson.
+const QueryModelVersion = 1
+
+// QueryJson is the query model sent by the frontend for every query.
+type QueryJson struct {
+	Version      int     `json:"version"`
+	RawSql       string  `json:"rawSql"`
+	Fill         bool    `json:"fill"`
+	FillInterval float64 `json:"fillInterval"`
+	FillMode     string  `json:"fillMode"`
+	FillValue    float64 `json:"fillValue"`
+	Format       string  `json:"format"`
+	DecimalMode  string  `json:"decimalMode"`
+	// Pivot reshapes table results into a crosstab, see PivotOptions.
+	Pivot *PivotOptions `json:"pivot,omitempty"`
+	// AdhocFilters are applied to the result columns of the query, see applyAdhocFilters.
+	AdhocFilters []AdhocFilter `json:"adhocFilters,omitempty"`
+	// Semantic is a query of a calculation view compiled by the backend, it replaces rawSql.
+	Semantic *SemanticQuery `json:"semantic,omitempty"`
+	// Builder is a query of the visual query builder compiled by the backend, it replaces rawSql.
+	Builder *BuilderQuery `json:"builder,omitempty"`
+	// Explain adds the plan of the query as second frame and execution statistics to the metadata.
+	Explain bool `json:"explain,omitempty"`
+
+	// RawQuery is false for queries built with the visual editor of the MySQL
+	// and PostgreSQL plugins before Grafana 9, which sent no rawSql.
+	RawQuery *bool `json:"rawQuery,omitempty"`
+	// TimeColumn and Select are only set by the pre Grafana 9 visual editor.
+	TimeColumn string          `json:"timeColumn,omitempty"`
+	Select     json.RawMessage `json:"select,omitempty"`
+}
+
+// formatAliases maps format names used by other SQL plugins and older versions of this plugin.
+var formatAliases = map[string]dataQueryFormat{
+	"":           dataQueryFormatSeries, // queries saved before the format property existed
+	"timeseries": dataQueryFormatSeries,
+	"timeSeries": dataQueryFormatSeries,
+	"series":     dataQueryFormatSeries,
+}
+
+// knownFormats are the formats accepted by executeQuery.
+var knownFormats = []dataQueryFormat{
+	dataQueryFormatTable,
+	dataQueryFormatSeries,
+	dataQueryFormatSeriesMulti,
+	dataQueryFormatLogs,
+	dataQueryFormatLogsVolume,
+	dataQueryFormatAnnotations,
+	dataQueryFormatNumeric,
+	dataQueryFormatHeatmap,
+}
+
+// ParseQueryJson reads the query model of a single query, migrates it to the
+// current version and validates it.
+func ParseQueryJson(raw json.RawMessage) (QueryJson, error) {
+	queryJson := QueryJson{}
+	if err := json.Unmarshal(raw, &queryJson); err != nil {
+		return queryJson, fmt.Errorf("error unmarshal query json: %w", err)
+	}
+	if queryJson.Version > QueryModelVersion {
+		return queryJson, fmt.Errorf("query model version %d is newer than the supported version %d, please update the plugin", queryJson.Version, QueryModelVersion)
+	}
+	if err := migrateQueryJson(&queryJson); err != nil {
+		return queryJson, err
+	}
+	return queryJson, validateQueryJson(queryJson)
+}
+
+// migrateQueryJson upgrades query models of older versions in place.
+func migrateQueryJson(queryJson *QueryJson) error {
+	if queryJson.Version < 1 {
+		// queries built with the old MySQL visual editor keep their SQL in the builder model only
+		if queryJson.RawSql == "" && queryJson.RawQuery != nil && !*queryJson.RawQuery &&
+			(queryJson.TimeColumn != "" || len(queryJson.Select) > 0) {
+			return errors.New("queries built with the legacy visual editor of another SQL plugin are not supported, please switch the query to code mode and save it again")
+		}
+		if f, ok := formatAliases[queryJson.Format]; ok {
+			queryJson.Format = string(f)
+		}
+		queryJson.Format = strings.ToLower(queryJson.Format)
+		queryJson.Version = 1
+	}
+	return nil
+}
+
+func validateQueryJson(queryJson QueryJson) error {
+	known := false
+	for _, f := range knownFormats {
+		if dataQueryFormat(queryJson.Format) == f {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unrecognized query model format: %q", queryJson.Format)
+	}
+	if _, err := ParseDecimalMode(queryJson.DecimalMode); err != nil {
+		return err
+	}
+	for _, f := range queryJson.AdhocFilters {
+		if err := f.validate(); err != nil {
+			return err
+		}
+	}
+	if queryJson.Semantic != nil && queryJson.Builder != nil {
+		return errors.New("a query is either a semantic or a builder query")
+	}
+	if queryJson.Semantic != nil {
+		if err := queryJson.Semantic.validate(); err != nil {
+			return err
+		}
+	}
+	if queryJson.Builder != nil {
+		if err := queryJson.Builder.validate(); err != nil {
+			return err
+		}
+	}
+	if queryJson.Pivot != nil {
+		if dataQueryFormat(queryJson.Format) != dataQueryFormatTable {
+			return errors.New("pivot is only supported for the table format")
+		}
+		if err := queryJson.Pivot.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryErrorResponse returns the response for a query which failed before execution.
+func queryErrorResponse(err error) backend.DataResponse {
+	return backend.DataResponse{
+		Error:       err,
+		ErrorSource: backend.ErrorSourcePlugin,
+		Status:      backend.StatusBadRequest,
+	}
+}
+
+// structured reports whether the SQL of the query is compiled from a structured model.
+func (q QueryJson) structured() bool {
+	return q.Semantic != nil || q.Builder != nil
+}
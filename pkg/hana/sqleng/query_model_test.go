@@ -0,0 +1,42 @@
+package sqleng
+
+import (
+	"testing"
+)
+
+func TestParseQueryJson(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       string
+		wantFormat string
+		wantErr    bool
+	}{
+		{name: "current", json: `{"version":1,"rawSql":"select 1 from dummy","format":"table"}`, wantFormat: "table"},
+		{name: "pre format", json: `{"rawSql":"select 1 from dummy"}`, wantFormat: "time_series"},
+		{name: "mysql plugin", json: `{"rawSql":"select 1","format":"time_series","rawQuery":true,"dataset":"db","editorMode":"code"}`, wantFormat: "time_series"},
+		{name: "multi frame", json: `{"version":1,"rawSql":"select 1 from dummy","format":"time_series_multi"}`, wantFormat: "time_series_multi"},
+		{name: "alias", json: `{"rawSql":"select 1","format":"timeSeries"}`, wantFormat: "time_series"},
+		{name: "legacy visual editor", json: `{"rawQuery":false,"timeColumn":"time","select":[[{"type":"column","params":["value"]}]]}`, wantErr: true},
+		{name: "unknown format", json: `{"rawSql":"select 1","format":"graph"}`, wantErr: true},
+		{name: "unknown decimal mode", json: `{"rawSql":"select 1","format":"table","decimalMode":"bcd"}`, wantErr: true},
+		{name: "newer version", json: `{"version":99,"rawSql":"select 1","format":"table"}`, wantErr: true},
+		{name: "invalid json", json: `{"rawSql":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQueryJson([]byte(tt.json))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.Format != tt.wantFormat || q.Version != QueryModelVersion {
+				t.Fatalf("got format %q version %d", q.Format, q.Version)
+			}
+		})
+	}
+}
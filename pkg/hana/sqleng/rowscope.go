@@ -0,0 +1,173 @@
+package sqleng
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// scopeAllValues grants all values of a scoped column.
+const scopeAllValues = "*"
+
+// aliasStopKeywords may follow a table reference without alias.
+var aliasStopKeywords = []string{"WHERE", "GROUP", "ORDER", "HAVING", "LIMIT", "OFFSET", "UNION", "EXCEPT", "INTERSECT",
+	"MINUS", "JOIN", "INNER", "LEFT", "RIGHT", "FULL", "CROSS", "OUTER", "ON", "USING", "WITH", "FOR", "WINDOW"}
+
+// RowScope restricts the rows the matching users may read to the given values of a column.
+// Users are matched by login or email, roles by their organization role. Grafana does not
+// pass team memberships to data sources, so teams are listed by the logins of their members.
+type RowScope struct {
+	Users  []string `json:"users,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+	Column string   `json:"column"`
+	Values []string `json:"values"`
+}
+
+func (s RowScope) matches(origin queryOrigin) bool {
+	is := func(v string) func(string) bool {
+		return func(s string) bool { return v != "" && strings.EqualFold(s, v) }
+	}
+	return slices.ContainsFunc(s.Users, is(origin.user)) || slices.ContainsFunc(s.Users, is(origin.email)) ||
+		slices.ContainsFunc(s.Roles, is(origin.role))
+}
+
+// scopedColumn is a column whose rows a user may read. All is set if the user may read all
+// values, denied if no scope of the column applies to the user.
+type scopedColumn struct {
+	name   string
+	values []string
+	all    bool
+	denied bool
+}
+
+// rowScopeColumns returns the scoped columns with the values a user may read, the union of
+// all scopes matching the user.
+func rowScopeColumns(scopes []RowScope, origin queryOrigin) []scopedColumn {
+	byName := map[string]*scopedColumn{}
+	var columns []*scopedColumn
+	for _, s := range scopes {
+		key := strings.ToUpper(s.Column)
+		c, ok := byName[key]
+		if !ok {
+			c = &scopedColumn{name: s.Column, denied: true}
+			byName[key] = c
+			columns = append(columns, c)
+		}
+		if !s.matches(origin) {
+			continue
+		}
+		c.denied = false
+		for _, v := range s.Values {
+			if v == scopeAllValues {
+				c.all = true
+			} else if !slices.Contains(c.values, v) {
+				c.values = append(c.values, v)
+			}
+		}
+	}
+	res := make([]scopedColumn, len(columns))
+	for i, c := range columns {
+		sort.Strings(c.values)
+		res[i] = *c
+	}
+	return res
+}
+
+// hasAlias reports whether the token at i starts the alias of a table reference.
+func hasAlias(tokens []sqlToken, i int) bool {
+	if i >= len(tokens) {
+		return false
+	}
+	t := tokens[i]
+	return t.kind == tokenQuoted || (t.kind == tokenWord && !slices.Contains(aliasStopKeywords, t.text))
+}
+
+// scopeStatement replaces every table the statement reads which has a scoped column by a
+// subquery filtering the column, so no part of the statement sees other rows. The subquery
+// keeps the alias of the table, or is named like it. It returns the scoped statement and a
+// description of the applied filters.
+func scopeStatement(sql string, columns []scopedColumn, loc *time.Location, tableColumns func(tableReference) ([]CatalogColumn, error)) (string, []string, error) {
+	tokens := tokenizeSQL(sql)
+	if err := checkSelectStatement(tokens); err != nil {
+		return "", nil, err
+	}
+	// every table list item is a table, which is scoped, or a subquery, whose tables are
+	// scoped, so a list which is not understood is rejected
+	refs, err := tableReferences(tokens)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot scope the rows of the query: %w", err)
+	}
+	runes := []rune(sql)
+	var applied []string
+	for i := len(refs) - 1; i >= 0; i-- {
+		ref := refs[i]
+		if publicObject(ref) {
+			continue
+		}
+		catalogColumns, err := tableColumns(ref)
+		if err != nil {
+			return "", nil, fmt.Errorf("cannot read the columns of %s to scope its rows: %w", ref, err)
+		}
+		tableCols := make([]adhocColumn, len(catalogColumns))
+		for i, c := range catalogColumns {
+			tableCols[i] = adhocColumn{name: c.Name, typeName: c.Type}
+		}
+
+		var conditions []string
+		for _, c := range columns {
+			col, err := findAdhocColumn(tableCols, c.name)
+			if err != nil || c.all {
+				continue
+			}
+			if c.denied || len(c.values) == 0 {
+				return "", nil, fmt.Errorf("no row scope of column %s applies to this user, so %s cannot be read", c.name, ref)
+			}
+			literals := make([]string, len(c.values))
+			for i, v := range c.values {
+				if literals[i], err = typedLiteral(col.typeName, v, loc); err != nil {
+					return "", nil, fmt.Errorf("row scope of column %s: %w", c.name, err)
+				}
+			}
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", quoteIdentifier(col.name), strings.Join(literals, ", ")))
+		}
+		if len(conditions) == 0 {
+			continue
+		}
+
+		// the source includes the arguments of a table function or the placeholders of a view
+		last := ref.last
+		if last+1 < len(tokens) && tokens[last+1].is(tokenSymbol, "(") {
+			last = closingParen(tokens, last+1)
+		}
+		start, end := tokens[ref.first].start, tokens[last].end
+		condition := strings.Join(conditions, " AND ")
+		scoped := fmt.Sprintf("(SELECT * FROM %s WHERE %s)", string(runes[start:end]), condition)
+		if !hasAlias(tokens, last+1) {
+			scoped += " AS " + quoteIdentifier(ref.name)
+		}
+		runes = append(runes[:start], append([]rune(scoped), runes[end:]...)...)
+		applied = append(applied, ref.String()+": "+condition)
+	}
+	slices.Reverse(applied)
+	return string(runes), applied, nil
+}
+
+// applyRowScopes restricts the rows a query reads to the scopes of the user who sent it.
+// Every scoped and every rejected query is logged for auditing.
+func (e *DataSourceHandler) applyRowScopes(ctx context.Context, sql string, origin queryOrigin) (string, error) {
+	scopes := e.dsInfo.JsonData.RowScopes
+	if len(scopes) == 0 {
+		return sql, nil
+	}
+	logger := e.log.FromContext(ctx)
+	scoped, applied, err := scopeStatement(sql, rowScopeColumns(scopes, origin), e.location(), e.referenceColumns(ctx))
+	if err != nil {
+		logger.Warn("Row scoping rejected a query", "user", origin.user, "role", origin.role, "dashboardUID", origin.dashboardUID, "error", err)
+		return "", err
+	}
+	logger.Info("Row scoping applied", "user", origin.user, "role", origin.role, "dashboardUID", origin.dashboardUID, "scopes", applied)
+	return scoped, nil
+}
@@ -0,0 +1,110 @@
+package sqleng
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRowScopes = []RowScope{
+	{Users: []string{"controller"}, Column: "MANDT", Values: []string{"100"}},
+	{Users: []string{"controller"}, Column: "BUKRS", Values: []string{"1000", "2000"}},
+	{Roles: []string{"Admin"}, Column: "MANDT", Values: []string{scopeAllValues}},
+	{Roles: []string{"Admin"}, Column: "BUKRS", Values: []string{scopeAllValues}},
+}
+
+func testTableColumns(ref tableReference) ([]CatalogColumn, error) {
+	switch ref.name {
+	case "ACDOCA":
+		return []CatalogColumn{{Name: "MANDT", Type: "NVARCHAR"}, {Name: "BUKRS", Type: "NVARCHAR"}, {Name: "HSL", Type: "DECIMAL"}}, nil
+	case "T001":
+		return []CatalogColumn{{Name: "MANDT", Type: "NVARCHAR"}, {Name: "BUTXT", Type: "NVARCHAR"}}, nil
+	case "TCURC":
+		return []CatalogColumn{{Name: "WAERS", Type: "NVARCHAR"}}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestRowScopeColumns(t *testing.T) {
+	columns := rowScopeColumns(testRowScopes, queryOrigin{user: "Controller", role: "Viewer"})
+	if len(columns) != 2 || columns[0].name != "MANDT" || columns[1].values[1] != "2000" || columns[0].all || columns[0].denied {
+		t.Fatalf("got %+v", columns)
+	}
+	columns = rowScopeColumns(testRowScopes, queryOrigin{user: "admin", role: "Admin"})
+	if !columns[0].all || !columns[1].all {
+		t.Fatalf("got %+v", columns)
+	}
+	columns = rowScopeColumns(testRowScopes, queryOrigin{user: "viewer", role: "Viewer"})
+	if !columns[0].denied || !columns[1].denied {
+		t.Fatalf("got %+v", columns)
+	}
+}
+
+func TestScopeStatement(t *testing.T) {
+	columns := rowScopeColumns(testRowScopes, queryOrigin{user: "controller"})
+	sql := `SELECT t.BUTXT, SUM(a.HSL) FROM SAPHANADB.ACDOCA a JOIN "SAPHANADB"."T001" t ON t.MANDT = a.MANDT, TCURC
+WHERE a.BUKRS IN (SELECT BUKRS FROM acdoca) -- FROM ACDOCA
+GROUP BY t.BUTXT`
+	got, applied, err := scopeStatement(sql, columns, time.UTC, testTableColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT t.BUTXT, SUM(a.HSL) FROM (SELECT * FROM SAPHANADB.ACDOCA WHERE "MANDT" IN (N'100') AND "BUKRS" IN (N'1000', N'2000')) a` +
+		` JOIN (SELECT * FROM "SAPHANADB"."T001" WHERE "MANDT" IN (N'100')) t ON t.MANDT = a.MANDT, TCURC
+WHERE a.BUKRS IN (SELECT BUKRS FROM (SELECT * FROM acdoca WHERE "MANDT" IN (N'100') AND "BUKRS" IN (N'1000', N'2000')) AS "ACDOCA") -- FROM ACDOCA
+GROUP BY t.BUTXT`
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	if len(applied) != 3 || !strings.HasPrefix(applied[0], "SAPHANADB.ACDOCA: ") {
+		t.Fatalf("got %v", applied)
+	}
+
+	got, _, err = scopeStatement("SELECT * FROM (ACDOCA a JOIN T001 t ON 1=1)", columns, time.UTC, testTableColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `SELECT * FROM ((SELECT * FROM ACDOCA WHERE "MANDT" IN (N'100') AND "BUKRS" IN (N'1000', N'2000')) a` +
+		` JOIN (SELECT * FROM T001 WHERE "MANDT" IN (N'100')) t ON 1=1)`
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	got, _, err = scopeStatement(`SELECT * FROM "_SYS_BIC"."fin/ACDOCA" ('PLACEHOLDER' = ('$$P$$', 'x')) WHERE 1 = 1`, columns, time.UTC,
+		func(tableReference) ([]CatalogColumn, error) { return testTableColumns(tableReference{name: "T001"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `SELECT * FROM (SELECT * FROM "_SYS_BIC"."fin/ACDOCA" ('PLACEHOLDER' = ('$$P$$', 'x')) WHERE "MANDT" IN (N'100')) AS "fin/ACDOCA" WHERE 1 = 1`
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestScopeStatementRejects(t *testing.T) {
+	viewer := rowScopeColumns(testRowScopes, queryOrigin{user: "viewer"})
+	if _, _, err := scopeStatement("SELECT * FROM ACDOCA", viewer, time.UTC, testTableColumns); err == nil || !strings.Contains(err.Error(), "no row scope of column MANDT") {
+		t.Fatalf("got %v", err)
+	}
+	if _, _, err := scopeStatement("SELECT * FROM TCURC", viewer, time.UTC, testTableColumns); err != nil {
+		t.Fatalf("unscoped table rejected: %v", err)
+	}
+	if _, _, err := scopeStatement("SELECT * FROM SECRET_SYNONYM", viewer, time.UTC, testTableColumns); err == nil {
+		t.Fatal("expected an unknown object to be rejected")
+	}
+	if _, _, err := scopeStatement("SELECT * FROM (ACDOCA a JOIN T001 t ON 1=1)", viewer, time.UTC, testTableColumns); err == nil || !strings.Contains(err.Error(), "no row scope of column MANDT") {
+		t.Fatalf("got %v", err)
+	}
+	if _, _, err := scopeStatement("SELECT * FROM TCURC, :tab", viewer, time.UTC, testTableColumns); err == nil {
+		t.Fatal("expected a table list which is not understood to be rejected")
+	}
+	if _, _, err := scopeStatement("CALL EXPORT_ACDOCA()", viewer, time.UTC, testTableColumns); err == nil {
+		t.Fatal("expected a procedure call to be rejected")
+	}
+
+	admin := rowScopeColumns(testRowScopes, queryOrigin{role: "Admin"})
+	if got, _, err := scopeStatement("SELECT * FROM ACDOCA", admin, time.UTC, testTableColumns); err != nil || got != "SELECT * FROM ACDOCA" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
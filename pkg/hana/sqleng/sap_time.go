@@ -0,0 +1,110 @@
+package sqleng
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Layouts of the character based SAP date and time types. ABAP tables store dates as
+// DATS (NVARCHAR(8), YYYYMMDD) and times as TIMS (NVARCHAR(6), HHMMSS); a timestamp is
+// usually selected as the concatenation of both.
+const (
+	sapDateLayout     = "20060102"
+	sapDateTimeLayout = "20060102150405"
+)
+
+// textTimeLayouts are the other textual timestamps accepted for SAP time columns.
+var textTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// location returns the time zone of the data source, used for dates without zone information.
+func (e *DataSourceHandler) location() *time.Location {
+	return ParseLocation(e.dsInfo.JsonData.Timezone)
+}
+
+// ParseLocation parses an IANA zone name or a "+hh:mm" offset. Unknown zones fall back to UTC.
+func ParseLocation(tz string) *time.Location {
+	tz = strings.TrimSpace(tz)
+	if tz == "" {
+		return time.UTC
+	}
+	if loc, err := time.LoadLocation(tz); err == nil {
+		return loc
+	}
+	if t, err := time.Parse("-07:00", tz); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset)
+	}
+	return time.UTC
+}
+
+// isInitialSAPDate reports whether s is the initial value of a DATS or TIMS field,
+// which SAP uses instead of NULL.
+func isInitialSAPDate(s string) bool {
+	return s == "" || strings.Trim(s, "0") == ""
+}
+
+// parseSAPTime parses a DATS (YYYYMMDD), a DATS and TIMS concatenation (YYYYMMDDHHMMSS),
+// an epoch in seconds, milliseconds or nanoseconds, or an ISO timestamp. Dates without zone
+// are interpreted in loc. Initial SAP dates return nil.
+func parseSAPTime(s string, loc *time.Location) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if isInitialSAPDate(s) {
+		return nil, nil
+	}
+	if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+		switch len(s) {
+		case len(sapDateLayout):
+			t, err := time.ParseInLocation(sapDateLayout, s, loc)
+			return &t, err
+		case len(sapDateTimeLayout):
+			t, err := time.ParseInLocation(sapDateTimeLayout, s, loc)
+			return &t, err
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		t := time.UnixMilli(int64(epochPrecisionToMS(f)))
+		return &t, nil
+	}
+	for _, layout := range textTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to parse %q as SAP date, epoch or timestamp", s)
+}
+
+// sapTimeAt returns the value of a time, numeric or string field as time. Integers with
+// the length of a DATS or DATS and TIMS value are read as such instead of as epoch.
+func sapTimeAt(f *data.Field, i int, loc *time.Location) (*time.Time, error) {
+	v, ok := f.ConcreteAt(i)
+	if !ok {
+		return nil, nil
+	}
+	switch x := v.(type) {
+	case time.Time:
+		return &x, nil
+	case string:
+		return parseSAPTime(x, loc)
+	}
+	fv, err := f.NullableFloatAt(i)
+	if err != nil || fv == nil {
+		return nil, fmt.Errorf("unable to convert %T to a time", v)
+	}
+	if *fv == math.Trunc(*fv) && *fv >= 0 {
+		if s := strconv.FormatFloat(*fv, 'f', 0, 64); len(s) == len(sapDateLayout) || len(s) == len(sapDateTimeLayout) {
+			return parseSAPTime(s, loc)
+		}
+	}
+	t := time.UnixMilli(int64(epochPrecisionToMS(*fv)))
+	return &t, nil
+}
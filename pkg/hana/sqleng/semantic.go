@@ -0,0 +1,311 @@
+package sqleng
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	calcViewMeasuresQuery = `SELECT MEASURE_NAME, MEASURE_AGGREGATOR FROM _SYS_BI.BIMC_MEASURES` +
+		` WHERE CATALOG_NAME = ? AND CUBE_NAME = ? ORDER BY MEASURE_NAME`
+	calcViewColumnsQuery = `SELECT COLUMN_NAME, DATA_TYPE_NAME FROM SYS.VIEW_COLUMNS` +
+		` WHERE SCHEMA_NAME = ? AND VIEW_NAME = ? ORDER BY POSITION`
+)
+
+// measureAggregators maps the MDX aggregator codes of BIMC_MEASURES to the aggregation
+// applied by semantic queries. Counts and distinct counts are already computed per row of
+// the view, so they add up. Calculated measures are computed by the view after the
+// aggregation of their operands, which it derives from the SUM of the query.
+var measureAggregators = map[string]string{
+	"1":   "SUM",
+	"2":   "SUM",
+	"3":   "MIN",
+	"4":   "MAX",
+	"5":   "AVG",
+	"8":   "SUM",
+	"127": "SUM",
+	"SUM": "SUM",
+	"MIN": "MIN",
+	"MAX": "MAX",
+	"AVG": "AVG",
+}
+
+// intervalArgPattern and fillArgPattern restrict the macro arguments of structured queries.
+var (
+	intervalArgPattern = regexp.MustCompile(`^(\$__interval|\d+(ms|s|m|h|d|w|M|y))$`)
+	fillArgPattern     = regexp.MustCompile(`^([A-Za-z_-]+|-?\d+(\.\d+)?)$`)
+)
+
+// SemanticQuery is a query of the measures of a calculation view, grouped by dimensions.
+// The backend compiles it to SQL, aggregating every measure as defined by the view.
+type SemanticQuery struct {
+	Schema string `json:"schema,omitempty"`
+	View   string `json:"view"`
+	// Parameters are the values of the input parameters, keyed by name.
+	Parameters    map[string]string      `json:"parameters,omitempty"`
+	Measures      []string               `json:"measures"`
+	Dimensions    []string               `json:"dimensions,omitempty"`
+	TimeDimension *SemanticTimeDimension `json:"timeDimension,omitempty"`
+	Filters       []AdhocFilter          `json:"filters,omitempty"`
+	Limit         int64                  `json:"limit,omitempty"`
+}
+
+// SemanticTimeDimension groups a semantic query by time. The interval defaults to the
+// interval of the panel, fill is the fill mode of $__timeGroup.
+type SemanticTimeDimension struct {
+	Name     string `json:"name"`
+	Interval string `json:"interval,omitempty"`
+	Fill     string `json:"fill,omitempty"`
+}
+
+func (q SemanticQuery) validate() error {
+	if q.View == "" {
+		return errors.New("semantic query without view")
+	}
+	if len(q.Measures) == 0 {
+		return errors.New("semantic query without measures")
+	}
+	if td := q.TimeDimension; td != nil {
+		if td.Name == "" {
+			return errors.New("semantic query time dimension without name")
+		}
+		// both are macro arguments, they must not end the macro call
+		if td.Interval != "" && !intervalArgPattern.MatchString(td.Interval) {
+			return fmt.Errorf("invalid semantic query interval %q", td.Interval)
+		}
+		if td.Fill != "" && !fillArgPattern.MatchString(td.Fill) {
+			return fmt.Errorf("invalid semantic query fill %q", td.Fill)
+		}
+	}
+	if q.Limit < 0 {
+		return errors.New("semantic query limit must not be negative")
+	}
+	for _, f := range q.Filters {
+		if err := f.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CalcViewMeasure is a measure of a calculation view with its aggregation.
+type CalcViewMeasure struct {
+	Name        string `json:"name"`
+	Aggregation string `json:"aggregation"`
+}
+
+// CalcViewSemantics lists the measures and dimensions of a calculation view. All columns
+// which are not measures are dimensions.
+type CalcViewSemantics struct {
+	Schema     string            `json:"schema"`
+	Measures   []CalcViewMeasure `json:"measures"`
+	Dimensions []CatalogColumn   `json:"dimensions"`
+}
+
+// CatalogColumn is a column of a table or view.
+type CatalogColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// calcViewSchema returns the schema of a calculation view. Repository views, named
+// package/VIEW, are activated into _SYS_BIC.
+func calcViewSchema(schema, view, defaultSchema string) (string, error) {
+	switch {
+	case schema != "":
+		return schema, nil
+	case strings.Contains(view, "/"):
+		return repositorySchema, nil
+	case defaultSchema != "":
+		return defaultSchema, nil
+	}
+	return "", fmt.Errorf("the schema of calculation view %q is required", view)
+}
+
+// calcViewSemantics reads the measures and dimensions of a calculation view. They are
+// cached like the other catalog listings.
+func (e *DataSourceHandler) calcViewSemantics(ctx context.Context, schema, view string) (*CalcViewSemantics, error) {
+	schema, err := calcViewSchema(schema, view, e.dsInfo.JsonData.DefaultSchema)
+	if err != nil {
+		return nil, err
+	}
+	catalog, cube, err := calcViewCatalog(schema, view)
+	if err != nil {
+		return nil, err
+	}
+
+	key := "calculation-views/semantics?" + url.Values{"schema": {schema}, "view": {view}}.Encode()
+	if body, ok := e.metadataCache.get(key); ok {
+		s := &CalcViewSemantics{}
+		if err := json.Unmarshal(body, s); err == nil {
+			return s, nil
+		}
+	}
+
+	s := &CalcViewSemantics{Schema: schema, Measures: []CalcViewMeasure{}, Dimensions: []CatalogColumn{}}
+	aggregations := map[string]string{}
+	err = e.scanCatalog(ctx, 2, func(v []any) {
+		agg, ok := measureAggregators[strings.ToUpper(catalogString(v[1]))]
+		if !ok {
+			agg = "SUM"
+		}
+		aggregations[catalogString(v[0])] = agg
+	}, calcViewMeasuresQuery, catalog, cube)
+	if err != nil {
+		return nil, err
+	}
+	err = e.scanCatalog(ctx, 2, func(v []any) {
+		name := catalogString(v[0])
+		if agg, ok := aggregations[name]; ok {
+			s.Measures = append(s.Measures, CalcViewMeasure{Name: name, Aggregation: agg})
+		} else {
+			s.Dimensions = append(s.Dimensions, CatalogColumn{Name: name, Type: catalogString(v[1])})
+		}
+	}, calcViewColumnsQuery, schema, view)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Measures) == 0 && len(s.Dimensions) == 0 {
+		return nil, fmt.Errorf("calculation view %s.%s not found", schema, view)
+	}
+
+	if body, err := json.Marshal(s); err == nil {
+		e.metadataCache.set(key, body)
+	}
+	return s, nil
+}
+
+// calcViewSemanticsHandler lists the measures and dimensions of a calculation view.
+func (e *DataSourceHandler) calcViewSemanticsHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeResourceError(rw, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	values := r.URL.Query()
+	if values.Get("view") == "" {
+		writeResourceError(rw, http.StatusBadRequest, errors.New("the view parameter is required"))
+		return
+	}
+	s, err := e.calcViewSemantics(r.Context(), values.Get("schema"), values.Get("view"))
+	if err != nil {
+		e.log.Error("Calculation view semantics query failed", "error", err)
+		writeResourceError(rw, http.StatusInternalServerError, e.TransformQueryError(e.log, err))
+		return
+	}
+	body, err := json.Marshal(s)
+	if err != nil {
+		writeResourceError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeResourceJSON(rw, body)
+}
+
+// compileSemanticQuery returns the SQL of a semantic query. The time filter and time group
+// are rendered as macros and expanded by expand, the values are not.
+func compileSemanticQuery(q SemanticQuery, s *CalcViewSemantics, loc *time.Location, expand macroExpander) (string, error) {
+	measures := map[string]string{}
+	for _, m := range s.Measures {
+		measures[m.Name] = m.Aggregation
+	}
+	dimensions := make([]adhocColumn, len(s.Dimensions))
+	for i, d := range s.Dimensions {
+		dimensions[i] = adhocColumn{name: d.Name, typeName: d.Type}
+	}
+
+	var selects, groups []string
+	if q.TimeDimension != nil {
+		col, err := findAdhocColumn(dimensions, q.TimeDimension.Name)
+		if err != nil {
+			return "", fmt.Errorf("unknown time dimension %q", q.TimeDimension.Name)
+		}
+		interval := q.TimeDimension.Interval
+		if interval == "" {
+			interval = "$__interval"
+		}
+		args := []string{quoteIdentifier(col.name), quoteLiteral(interval)}
+		if q.TimeDimension.Fill != "" {
+			args = append(args, q.TimeDimension.Fill)
+		}
+		group, err := expand(fmt.Sprintf("$__timeGroup(%s)", strings.Join(args, ", ")))
+		if err != nil {
+			return "", err
+		}
+		selects = append(selects, group+` AS "time"`)
+		groups = append(groups, group)
+	}
+	for _, name := range q.Dimensions {
+		col, err := findAdhocColumn(dimensions, name)
+		if err != nil {
+			return "", fmt.Errorf("unknown dimension %q", name)
+		}
+		selects = append(selects, quoteIdentifier(col.name))
+		groups = append(groups, quoteIdentifier(col.name))
+	}
+	for _, name := range q.Measures {
+		agg, ok := measures[name]
+		if !ok {
+			return "", fmt.Errorf("unknown measure %q", name)
+		}
+		selects = append(selects, fmt.Sprintf("%s(%s) AS %s", agg, quoteIdentifier(name), quoteIdentifier(name)))
+	}
+
+	var conditions []string
+	if q.TimeDimension != nil {
+		col, _ := findAdhocColumn(dimensions, q.TimeDimension.Name)
+		filter, err := expand(fmt.Sprintf("$__timeFilter(%s)", quoteIdentifier(col.name)))
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, filter)
+	}
+	for _, f := range q.Filters {
+		col, err := findAdhocColumn(dimensions, f.Key)
+		if err != nil {
+			return "", fmt.Errorf("unknown filter dimension %q", f.Key)
+		}
+		cond, err := adhocCondition(f, col, loc)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, cond)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(selects, ", "))
+	fmt.Fprintf(&sb, "\nFROM %s.%s", quoteIdentifier(s.Schema), quoteIdentifier(q.View))
+	if clause := placeholderClause(q.Parameters); clause != "" {
+		sb.WriteString(" ")
+		sb.WriteString(clause)
+	}
+	if len(conditions) > 0 {
+		sb.WriteString("\nWHERE ")
+		sb.WriteString(strings.Join(conditions, " AND "))
+	}
+	if len(groups) > 0 {
+		sb.WriteString("\nGROUP BY ")
+		sb.WriteString(strings.Join(groups, ", "))
+		sb.WriteString("\nORDER BY ")
+		sb.WriteString(strings.Join(groups, ", "))
+	}
+	if q.Limit > 0 {
+		fmt.Fprintf(&sb, "\nLIMIT %d", q.Limit)
+	}
+	return sb.String(), nil
+}
+
+// compileSemantic compiles a semantic query with the metadata of its view.
+func (e *DataSourceHandler) compileSemantic(ctx context.Context, q SemanticQuery, expand macroExpander) (string, error) {
+	s, err := e.calcViewSemantics(ctx, q.Schema, q.View)
+	if err != nil {
+		return "", err
+	}
+	return compileSemanticQuery(q, s, e.location(), expand)
+}
@@ -0,0 +1,80 @@
+package sqleng
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileSemanticQuery(t *testing.T) {
+	s := &CalcViewSemantics{
+		Schema: "_SYS_BIC",
+		Measures: []CalcViewMeasure{
+			{Name: "REVENUE", Aggregation: "SUM"},
+			{Name: "MAX_PRICE", Aggregation: "MAX"},
+		},
+		Dimensions: []CatalogColumn{
+			{Name: "POSTING_DATE", Type: "TIMESTAMP"},
+			{Name: "COMPANY_CODE", Type: "NVARCHAR"},
+			{Name: "FISCAL_YEAR", Type: "INTEGER"},
+		},
+	}
+	q := SemanticQuery{
+		View:          "sales/CV_REVENUE",
+		Parameters:    map[string]string{"P_CURRENCY": "EUR"},
+		Measures:      []string{"REVENUE", "MAX_PRICE"},
+		Dimensions:    []string{"company_code"},
+		TimeDimension: &SemanticTimeDimension{Name: "POSTING_DATE", Fill: "0"},
+		Filters:       []AdhocFilter{{Key: "FISCAL_YEAR", Operator: ">", Value: "2020"}},
+		Limit:         100,
+	}
+	if err := q.validate(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := compileSemanticQuery(q, s, time.UTC, keepMacros)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := `$__timeGroup("POSTING_DATE", '$__interval', 0)`
+	want := `SELECT ` + group + ` AS "time", "COMPANY_CODE", SUM("REVENUE") AS "REVENUE", MAX("MAX_PRICE") AS "MAX_PRICE"` +
+		"\nFROM \"_SYS_BIC\".\"sales/CV_REVENUE\" ('PLACEHOLDER' = ('$$P_CURRENCY$$', 'EUR'))" +
+		"\nWHERE $__timeFilter(\"POSTING_DATE\") AND \"FISCAL_YEAR\" > 2020" +
+		"\nGROUP BY " + group + `, "COMPANY_CODE"` +
+		"\nORDER BY " + group + `, "COMPANY_CODE"` +
+		"\nLIMIT 100"
+	if got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+
+	for _, bad := range []SemanticQuery{
+		{View: q.View, Measures: []string{"COMPANY_CODE"}},
+		{View: q.View, Measures: []string{"REVENUE"}, Dimensions: []string{"MISSING"}},
+		{View: q.View, Measures: []string{"REVENUE"}, Filters: []AdhocFilter{{Key: "REVENUE", Operator: "=", Value: "1"}}},
+	} {
+		if _, err := compileSemanticQuery(bad, s, time.UTC, keepMacros); err == nil {
+			t.Fatalf("expected an error for %+v", bad)
+		}
+	}
+	// values are not expanded, the expander rewrites macros also inside of literals
+	expand := func(fragment string) (string, error) {
+		return strings.ReplaceAll(strings.ReplaceAll(fragment, "$__timeFrom()", "NOW()"), "$__timeFilter", "TIME_FILTER"), nil
+	}
+	got, err = compileSemanticQuery(SemanticQuery{
+		View:          q.View,
+		Parameters:    map[string]string{"P_FROM": "$__timeFrom()"},
+		Measures:      []string{"REVENUE"},
+		TimeDimension: &SemanticTimeDimension{Name: "POSTING_DATE"},
+		Filters:       []AdhocFilter{{Key: "COMPANY_CODE", Operator: "=", Value: "$__timeFrom()"}},
+	}, s, time.UTC, expand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `('PLACEHOLDER' = ('$$P_FROM$$', '$__timeFrom()'))`) ||
+		!strings.Contains(got, `WHERE TIME_FILTER("POSTING_DATE") AND "COMPANY_CODE" = N'$__timeFrom()'`) {
+		t.Fatalf("got %s", got)
+	}
+
+	if err := (SemanticQuery{View: q.View, Measures: q.Measures, TimeDimension: &SemanticTimeDimension{Name: "POSTING_DATE", Fill: "0) --"}}).validate(); err == nil {
+		t.Fatal("expected an error for an invalid fill")
+	}
+}
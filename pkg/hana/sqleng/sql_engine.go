@@ -18,6 +18,7 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
 )
@@ -25,8 +26,10 @@ import (
 var (
 	_ backend.QueryDataHandler      = (*DataSourceHandler)(nil)
 	_ backend.CheckHealthHandler    = (*DataSourceHandler)(nil)
+	_ backend.CallResourceHandler   = (*DataSourceHandler)(nil)
 	_ instancemgmt.InstanceDisposer = (*DataSourceHandler)(nil)
 )
+
 // MetaKeyExecutedQueryString is the key where the executed query should get stored
 const MetaKeyExecutedQueryString = "executedQueryString"
 
@@ -40,8 +43,10 @@ type SQLMacroEngine interface {
 type SqlQueryResultTransformer interface {
 	// TransformQueryError transforms a query error.
 	TransformQueryError(logger log.Logger, err error) error
-	GetConverterList() []sqlutil.StringConverter
-	GetConverterList2() []sqlutil.Converter
+	// GetConverterList returns the converters for the natively typed columns.
+	GetConverterList() []sqlutil.Converter
+	// GetConverterList2 returns the converters which depend on the query, such as decimal columns.
+	GetConverterList2(opts *ConverterOptions) []sqlutil.Converter
 }
 
 type JsonData struct {
@@ -65,7 +70,27 @@ type JsonData struct {
 	SecureDSProxyUsername   string `json:"secureSocksProxyUsername"`
 	AllowCleartextPasswords bool   `json:"allowCleartextPasswords"`
 	AuthenticationType      string `json:"authenticationType"`
-	DefaultSchema 			string `json:"defaultSchema"`
+	DefaultSchema           string `json:"defaultSchema"`
+	MaxFrameBytes           int64  `json:"maxFrameBytes"`
+	LobMaxLength            int64  `json:"lobMaxLength"`
+	DecimalMode             string `json:"decimalMode"`
+	MetadataCacheTTL        int64  `json:"metadataCacheTTL"`
+	// The cost guard rejects queries whose estimated plan cost or operator output size
+	// exceeds these limits, 0 disables the check. Dashboards are exempted by the header
+	// X-Dashboard-Uid, which clients can set, users by the login Grafana passes.
+	MaxEstimatedCost          float64  `json:"maxEstimatedCost"`
+	MaxEstimatedRows          float64  `json:"maxEstimatedRows"`
+	CostGuardExemptDashboards []string `json:"costGuardExemptDashboards"`
+	CostGuardExemptUsers      []string `json:"costGuardExemptUsers"`
+	// Queries may only read the allowed schemas and tables, given as SCHEMA.TABLE. Both
+	// empty allows all of them.
+	AllowedSchemas []string `json:"allowedSchemas"`
+	AllowedTables  []string `json:"allowedTables"`
+	// MaxTimeRange and MinRefreshInterval are durations like 30d or 1m, empty is unlimited.
+	MaxTimeRange       string `json:"maxTimeRange"`
+	MinRefreshInterval string `json:"minRefreshInterval"`
+	// RowScopes restrict the rows of the scoped columns every query reads per user.
+	RowScopes []RowScope `json:"rowScopes"`
 }
 
 type DataSourceInfo struct {
@@ -84,6 +109,9 @@ type DataPluginConfiguration struct {
 	TimeColumnNames   []string
 	MetricColumnTypes []string
 	RowLimit          int64
+	FrameByteLimit    int64
+	LobMaxLength      int64
+	MetadataCacheTTL  time.Duration
 }
 
 type DataSourceHandler struct {
@@ -95,16 +123,12 @@ type DataSourceHandler struct {
 	log                    log.Logger
 	dsInfo                 DataSourceInfo
 	rowLimit               int64
+	frameByteLimit         int64
+	lobMaxLength           int64
 	userError              string
-}
-
-type QueryJson struct {
-	RawSql       string  `json:"rawSql"`
-	Fill         bool    `json:"fill"`
-	FillInterval float64 `json:"fillInterval"`
-	FillMode     string  `json:"fillMode"`
-	FillValue    float64 `json:"fillValue"`
-	Format       string  `json:"format"`
+	metadataCache          *metadataCache
+	governance             *queryGovernance
+	resourceHandler        backend.CallResourceHandler
 }
 
 func (e *DataSourceHandler) TransformQueryError(logger log.Logger, err error) error {
@@ -130,8 +154,17 @@ func NewQueryDataHandler(userFacingDefaultError string, db *sql.DB, config DataP
 		log:                    log,
 		dsInfo:                 config.DSInfo,
 		rowLimit:               config.RowLimit,
+		frameByteLimit:         config.FrameByteLimit,
+		lobMaxLength:           config.LobMaxLength,
 		userError:              userFacingDefaultError,
+		metadataCache:          newMetadataCache(config.MetadataCacheTTL),
+	}
+
+	governance, err := newQueryGovernance(config.DSInfo.JsonData)
+	if err != nil {
+		return nil, err
 	}
+	queryDataHandler.governance = governance
 
 	if len(config.TimeColumnNames) > 0 {
 		queryDataHandler.timeColumnNames = config.TimeColumnNames
@@ -142,12 +175,14 @@ func NewQueryDataHandler(userFacingDefaultError string, db *sql.DB, config DataP
 	}
 
 	queryDataHandler.db = db
+	queryDataHandler.resourceHandler = httpadapter.New(queryDataHandler.newResourceMux())
 	return &queryDataHandler, nil
 }
 
 type DBDataResponse struct {
 	dataResponse backend.DataResponse
 	refID        string
+	explain      *queryExplain
 }
 
 func (e *DataSourceHandler) Dispose() {
@@ -164,29 +199,29 @@ func (e *DataSourceHandler) QueryData(ctx context.Context, req *backend.QueryDat
 	result := backend.NewQueryDataResponse()
 	ch := make(chan DBDataResponse, len(req.Queries))
 	var wg sync.WaitGroup
+	origin := requestOrigin(req)
 	// Execute each query in a goroutine and wait for them to finish afterwards
 	for _, query := range req.Queries {
-		queryjson := QueryJson{
-			Fill:   false,
-			Format: "time_series",
-		}
-		err := json.Unmarshal(query.JSON, &queryjson)
+		// a bad query model only fails its own query, not the whole request
+		queryjson, err := ParseQueryJson(query.JSON)
 		if err != nil {
-			return nil, fmt.Errorf("error unmarshal query json: %w", err)
+			ch <- DBDataResponse{dataResponse: queryErrorResponse(err), refID: query.RefID}
+			continue
 		}
 
 		// the fill-params are only stored inside this function, during query-interpolation. we do not support
 		// sending them in "from the outside"
 		if queryjson.Fill || queryjson.FillInterval != 0.0 || queryjson.FillMode != "" || queryjson.FillValue != 0.0 {
-			return nil, fmt.Errorf("query fill-parameters not supported")
+			ch <- DBDataResponse{dataResponse: queryErrorResponse(errors.New("query fill-parameters not supported")), refID: query.RefID}
+			continue
 		}
 
-		if queryjson.RawSql == "" {
+		if queryjson.RawSql == "" && !queryjson.structured() {
 			continue
 		}
 
 		wg.Add(1)
-		go e.executeQuery(query, &wg, ctx, ch, queryjson)
+		go e.executeQuery(query, &wg, ctx, ch, queryjson, origin)
 	}
 
 	wg.Wait()
@@ -195,14 +230,81 @@ func (e *DataSourceHandler) QueryData(ctx context.Context, req *backend.QueryDat
 	close(ch)
 	result.Responses = make(map[string]backend.DataResponse)
 	for queryResult := range ch {
+		if queryResult.explain != nil {
+			queryResult.explain.attach(&queryResult.dataResponse)
+		}
 		result.Responses[queryResult.refID] = queryResult.dataResponse
 	}
 
 	return result, nil
 }
 
+// queryBuildError is an error of a stage of buildQuery, with the SQL built so far.
+type queryBuildError struct {
+	stage  string
+	sql    string
+	source backend.ErrorSource
+	err    error
+}
+
+func (e *queryBuildError) Error() string { return fmt.Sprintf("%s: %v", e.stage, e.err) }
+
+func (e *queryBuildError) Unwrap() error { return e.err }
+
+// buildQuery returns the SQL executed for a query: the compiled structured query or the raw
+// SQL with the variables and macros interpolated, the row scopes of the user and the ad hoc
+// filters applied.
+func (e *DataSourceHandler) buildQuery(ctx context.Context, query *backend.DataQuery, queryJson QueryJson, origin queryOrigin) (string, error) {
+	timeRange := query.TimeRange
+
+	interpolate := func(sql string) (string, error) {
+		// global substitutions, then data source specific substitutions
+		sql = Interpolate(*query, timeRange, e.dsInfo.JsonData.TimeInterval, sql)
+		return e.macroEngine.Interpolate(query, timeRange, sql)
+	}
+
+	var interpolatedQuery string
+	var err error
+	switch {
+	case queryJson.Builder != nil:
+		// the compilers interpolate the fragments they generate, not the values of the query
+		if interpolatedQuery, err = e.compileBuilder(ctx, *queryJson.Builder, interpolate); err != nil {
+			return "", &queryBuildError{"compiling builder query failed", "", backend.ErrorSourcePlugin, err}
+		}
+	case queryJson.Semantic != nil:
+		if interpolatedQuery, err = e.compileSemantic(ctx, *queryJson.Semantic, interpolate); err != nil {
+			return "", &queryBuildError{"compiling semantic query failed", "", backend.ErrorSourcePlugin, err}
+		}
+	default:
+		if interpolatedQuery, err = interpolate(queryJson.RawSql); err != nil {
+			return "", &queryBuildError{"interpolation failed", interpolatedQuery, backend.ErrorSourcePlugin, err}
+		}
+	}
+
+	// the ad hoc filters run the query to read its result columns, so it is checked and
+	// scoped first
+	if err := e.governance.checkTables(interpolatedQuery, e.referenceColumns(ctx)); err != nil {
+		return "", &queryBuildError{"query rejected by the datasource governance", interpolatedQuery, backend.ErrorSourceDownstream, err}
+	}
+
+	scoped, err := e.applyRowScopes(ctx, interpolatedQuery, origin)
+	if err != nil {
+		return "", &queryBuildError{"applying row scopes failed", interpolatedQuery, backend.ErrorSourceDownstream, err}
+	}
+	interpolatedQuery = scoped
+
+	if len(queryJson.AdhocFilters) > 0 {
+		filtered, err := e.applyAdhocFilters(ctx, interpolatedQuery, queryJson.AdhocFilters)
+		if err != nil {
+			return "", &queryBuildError{"applying ad hoc filters failed", interpolatedQuery, backend.ErrorSourceDownstream, err}
+		}
+		interpolatedQuery = filtered
+	}
+	return interpolatedQuery, nil
+}
+
 func (e *DataSourceHandler) executeQuery(query backend.DataQuery, wg *sync.WaitGroup, queryContext context.Context,
-	ch chan DBDataResponse, queryJson QueryJson) {
+	ch chan DBDataResponse, queryJson QueryJson, origin queryOrigin) {
 	defer wg.Done()
 	queryResult := DBDataResponse{
 		dataResponse: backend.DataResponse{},
@@ -228,12 +330,10 @@ func (e *DataSourceHandler) executeQuery(query backend.DataQuery, wg *sync.WaitG
 		}
 	}()
 
-	if queryJson.RawSql == "" {
+	if queryJson.RawSql == "" && !queryJson.structured() {
 		panic("Query model property rawSql should not be empty at this point")
 	}
 
-	timeRange := query.TimeRange
-
 	errAppendDebug := func(frameErr string, err error, query string, source backend.ErrorSource) {
 		var emptyFrame data.Frame
 		emptyFrame.SetMeta(&data.FrameMeta{
@@ -245,16 +345,29 @@ func (e *DataSourceHandler) executeQuery(query backend.DataQuery, wg *sync.WaitG
 		ch <- queryResult
 	}
 
-	// global substitutions
-	interpolatedQuery := Interpolate(query, timeRange, e.dsInfo.JsonData.TimeInterval, queryJson.RawSql)
+	if err := e.governance.checkQuery(query, origin); err != nil {
+		errAppendDebug("query rejected by the datasource governance", err, "", backend.ErrorSourceDownstream)
+		return
+	}
 
-	// data source specific substitutions
-	interpolatedQuery, err := e.macroEngine.Interpolate(&query, timeRange, interpolatedQuery)
+	interpolatedQuery, err := e.buildQuery(queryContext, &query, queryJson, origin)
 	if err != nil {
-		errAppendDebug("interpolation failed", e.TransformQueryError(logger, err), interpolatedQuery, backend.ErrorSourcePlugin)
+		var buildErr *queryBuildError
+		if errors.As(err, &buildErr) {
+			errAppendDebug(buildErr.stage, e.TransformQueryError(logger, buildErr.err), buildErr.sql, buildErr.source)
+			return
+		}
+		errAppendDebug("building query failed", err, "", backend.ErrorSourcePlugin)
+		return
+	}
+
+	if err := e.checkQueryCost(queryContext, interpolatedQuery, origin); err != nil {
+		errAppendDebug("query rejected by the cost guard", err, interpolatedQuery, backend.ErrorSourceDownstream)
 		return
 	}
 
+	e.governance.recordRun(query, origin)
+	start := time.Now()
 	rows, err := e.db.QueryContext(queryContext, interpolatedQuery)
 	if err != nil {
 		errAppendDebug("db query error", e.TransformQueryError(logger, err), interpolatedQuery, backend.ErrorSourceDownstream)
@@ -271,21 +384,44 @@ func (e *DataSourceHandler) executeQuery(query backend.DataQuery, wg *sync.WaitG
 		errAppendDebug("failed to get configurations", err, interpolatedQuery, backend.ErrorSourcePlugin)
 		return
 	}
+	qm.fromAlert = origin.fromAlert
 
 	// Convert row.Rows to dataframe
-	stringConverters := e.queryResultTransformer.GetConverterList()
-	converts:=sqlutil.ToConverters(stringConverters...);
+	converts := e.queryResultTransformer.GetConverterList()
+
+	decimalMode := queryJson.DecimalMode
+	if decimalMode == "" {
+		decimalMode = e.dsInfo.JsonData.DecimalMode
+	}
+	converterOpts := &ConverterOptions{ColumnTypes: qm.columnTypes}
+	if converterOpts.DecimalMode, err = ParseDecimalMode(decimalMode); err != nil {
+		errAppendDebug("invalid decimal mode", err, interpolatedQuery, backend.ErrorSourcePlugin)
+		return
+	}
+	converts2 := e.queryResultTransformer.GetConverterList2(converterOpts)
 
-	converts2:=e.queryResultTransformer.GetConverterList2();
-	
 	converts = append(converts, converts2...)
 
-	frame, err := sqlutil.FrameFromRows(rows, e.rowLimit, converts...)
+	limits := frameLimits{
+		rows:      e.rowLimit,
+		bytes:     e.frameByteLimit,
+		lobLength: e.lobMaxLength,
+	}
+	frame, err := frameFromRows(rows, limits, converts...)
 	if err != nil {
 		errAppendDebug("convert frame from rows error", err, interpolatedQuery, backend.ErrorSourcePlugin)
 		return
 	}
 
+	if queryJson.Explain {
+		queryResult.explain = e.explainQuery(queryContext, query.RefID, interpolatedQuery, time.Since(start), frame.Rows())
+	}
+
+	if err := converterOpts.apply(frame); err != nil {
+		errAppendDebug("convert frame from rows error", err, interpolatedQuery, backend.ErrorSourcePlugin)
+		return
+	}
+
 	if frame.Meta == nil {
 		frame.Meta = &data.FrameMeta{}
 	}
@@ -303,11 +439,70 @@ func (e *DataSourceHandler) executeQuery(query backend.DataQuery, wg *sync.WaitG
 		return
 	}
 
+	// annotation time columns may hold SAP dates, so they are converted with the annotation columns
+	if qm.Format == dataQueryFormatAnnotations {
+		if frame, err = convertToAnnotationsFrame(frame, e.location()); err != nil {
+			errAppendDebug("converting to annotations frame failed", err, interpolatedQuery, backend.ErrorSourceDownstream)
+			return
+		}
+		queryResult.dataResponse.Frames = data.Frames{frame}
+		ch <- queryResult
+		return
+	}
+
 	if err := convertSQLTimeColumnsToEpochMS(frame, qm); err != nil {
 		errAppendDebug("converting time columns failed", err, interpolatedQuery, backend.ErrorSourcePlugin)
 		return
 	}
 
+	if qm.Format == dataQueryFormatTable && queryJson.Pivot != nil {
+		if frame, err = pivotFrame(frame, queryJson.Pivot); err != nil {
+			errAppendDebug("pivot failed", err, interpolatedQuery, backend.ErrorSourceDownstream)
+			return
+		}
+	}
+
+	if qm.Format == dataQueryFormatSeriesMulti && qm.timeIndex == -1 {
+		errAppendDebug("db has no time column", errors.New("time column is missing; make sure your data includes a time column for time series format or switch to a table format that doesn't require it"), interpolatedQuery, backend.ErrorSourceDownstream)
+		return
+	}
+
+	// alert rules get frames of the data plane contract with all labels kept, instead of the
+	// wide frames and legacy series names of panels
+	if qm.Format == dataQueryFormatNumeric || qm.Format == dataQueryFormatSeriesMulti ||
+		(qm.fromAlert && (qm.Format == dataQueryFormatSeries || qm.Format == dataQueryFormatTable)) {
+		frames, err := dataplaneFrames(frame, qm)
+		if err != nil {
+			errAppendDebug("converting to numeric frames failed", err, interpolatedQuery, backend.ErrorSourceDownstream)
+			return
+		}
+		queryResult.dataResponse.Frames = frames
+		ch <- queryResult
+		return
+	}
+
+	switch qm.Format {
+	case dataQueryFormatLogs:
+		if frame, err = convertToLogsFrame(frame, qm); err != nil {
+			errAppendDebug("converting to logs frame failed", err, interpolatedQuery, backend.ErrorSourceDownstream)
+			return
+		}
+	case dataQueryFormatHeatmap:
+		if frame, err = convertToHeatmapFrame(frame, qm); err != nil {
+			errAppendDebug("converting to heatmap frame failed", err, interpolatedQuery, backend.ErrorSourceDownstream)
+			return
+		}
+	case dataQueryFormatLogsVolume:
+		frames, err := logsVolumeFrames(frame, qm, query.Interval)
+		if err != nil {
+			errAppendDebug("converting to logs volume failed", err, interpolatedQuery, backend.ErrorSourceDownstream)
+			return
+		}
+		queryResult.dataResponse.Frames = frames
+		ch <- queryResult
+		return
+	}
+
 	if qm.Format == dataQueryFormatSeries {
 		// time series has to have time column
 		if qm.timeIndex == -1 {
@@ -334,6 +529,14 @@ func (e *DataSourceHandler) executeQuery(query backend.DataQuery, wg *sync.WaitG
 			}
 		}
 
+		if qm.fill != nil {
+			var err error
+			if frame, err = fillFrame(frame, qm.timeIndex, qm.fill, qm.TimeRange); err != nil {
+				errAppendDebug("failed to fill time series", err, interpolatedQuery, backend.ErrorSourcePlugin)
+				return
+			}
+		}
+
 		tsSchema := frame.TimeSeriesSchema()
 		if tsSchema.Type == data.TimeSeriesTypeLong {
 			var err error
@@ -359,21 +562,6 @@ func (e *DataSourceHandler) executeQuery(query backend.DataQuery, wg *sync.WaitG
 				}
 			}
 		}
-		if qm.FillMissing != nil {
-			// we align the start-time
-			startUnixTime := qm.TimeRange.From.Unix() / int64(qm.Interval.Seconds()) * int64(qm.Interval.Seconds())
-			alignedTimeRange := backend.TimeRange{
-				From: time.Unix(startUnixTime, 0),
-				To:   qm.TimeRange.To,
-			}
-
-			var err error
-			frame, err = sqlutil.ResampleWideFrame(frame, qm.FillMissing, alignedTimeRange, qm.Interval)
-			if err != nil {
-				logger.Error("Failed to resample dataframe", "err", err)
-				frame.AppendNotices(data.Notice{Text: "Failed to resample dataframe", Severity: data.NoticeSeverityWarning})
-			}
-		}
 	}
 
 	queryResult.dataResponse.Frames = data.Frames{frame}
@@ -413,8 +601,8 @@ func (e *DataSourceHandler) newProcessCfg(query backend.DataQuery, queryContext
 		queryContext: queryContext,
 	}
 
-	queryJson := QueryJson{}
-	err = json.Unmarshal(query.JSON, &queryJson)
+	// the macro engine may have added fill parameters to the query json during interpolation
+	queryJson, err := ParseQueryJson(query.JSON)
 	if err != nil {
 		return nil, err
 	}
@@ -422,29 +610,30 @@ func (e *DataSourceHandler) newProcessCfg(query backend.DataQuery, queryContext
 	if queryJson.Fill {
 		qm.FillMissing = &data.FillMissing{}
 		qm.Interval = time.Duration(queryJson.FillInterval * float64(time.Second))
-		switch strings.ToLower(queryJson.FillMode) {
-		case "null":
+		qm.fill = &fillOptions{
+			mode:     strings.ToLower(queryJson.FillMode),
+			value:    queryJson.FillValue,
+			interval: qm.Interval,
+			location: e.location(),
+		}
+		switch qm.fill.mode {
+		case fillModeNull:
 			qm.FillMissing.Mode = data.FillModeNull
-		case "previous":
+		case fillModePrevious:
 			qm.FillMissing.Mode = data.FillModePrevious
-		case "value":
+		case fillModeValue:
 			qm.FillMissing.Mode = data.FillModeValue
 			qm.FillMissing.Value = queryJson.FillValue
 		default:
+			// the other modes are only applied by fillFrame
+			qm.FillMissing.Mode = data.FillModeNull
 		}
 	}
 
 	qm.TimeRange.From = query.TimeRange.From.UTC()
 	qm.TimeRange.To = query.TimeRange.To.UTC()
 
-	switch queryJson.Format {
-	case "time_series":
-		qm.Format = dataQueryFormatSeries
-	case "table":
-		qm.Format = dataQueryFormatTable
-	default:
-		panic(fmt.Sprintf("Unrecognized query model format: %q", queryJson.Format))
-	}
+	qm.Format = dataQueryFormat(queryJson.Format)
 
 	for i, col := range qm.columnNames {
 		for _, tc := range e.timeColumnNames {
@@ -486,6 +675,18 @@ const (
 	dataQueryFormatTable dataQueryFormat = "table"
 	// dataQueryFormatSeries identifies a time series query.
 	dataQueryFormatSeries dataQueryFormat = "time_series"
+	// dataQueryFormatSeriesMulti identifies a time series query returning one frame per series.
+	dataQueryFormatSeriesMulti dataQueryFormat = "time_series_multi"
+	// dataQueryFormatLogs identifies a logs query.
+	dataQueryFormatLogs dataQueryFormat = "logs"
+	// dataQueryFormatLogsVolume identifies the logs volume supplementary query of a logs query.
+	dataQueryFormatLogsVolume dataQueryFormat = "logs_volume"
+	// dataQueryFormatAnnotations identifies an annotation query.
+	dataQueryFormatAnnotations dataQueryFormat = "annotations"
+	// dataQueryFormatNumeric identifies a query returning data plane numeric or time series frames.
+	dataQueryFormatNumeric dataQueryFormat = "numeric"
+	// dataQueryFormatHeatmap identifies a query returning bucket counts for a heatmap.
+	dataQueryFormatHeatmap dataQueryFormat = "heatmap"
 )
 
 type dataQueryModel struct {
@@ -493,6 +694,7 @@ type dataQueryModel struct {
 	Format            dataQueryFormat
 	TimeRange         backend.TimeRange
 	FillMissing       *data.FillMissing // property not set until after Interpolate()
+	fill              *fillOptions      // property not set until after Interpolate()
 	Interval          time.Duration
 	columnNames       []string
 	columnTypes       []*sql.ColumnType
@@ -501,6 +703,7 @@ type dataQueryModel struct {
 	metricIndex       int
 	metricPrefix      bool
 	queryContext      context.Context
+	fromAlert         bool // the query is evaluated by an alert or recording rule
 }
 
 func convertSQLTimeColumnsToEpochMS(frame *data.Frame, qm *dataQueryModel) error {
@@ -598,11 +801,11 @@ func SetupFillmode(query *backend.DataQuery, interval time.Duration, fillmode st
 
 	switch fillmode {
 	case "NULL":
-		rawQueryProp["fillMode"] = "null"
-	case "previous":
-		rawQueryProp["fillMode"] = "previous"
+		rawQueryProp["fillMode"] = fillModeNull
+	case fillModePrevious, fillModeLinear, fillModeNext, fillModeZeroAfterLast:
+		rawQueryProp["fillMode"] = fillmode
 	default:
-		rawQueryProp["fillMode"] = "value"
+		rawQueryProp["fillMode"] = fillModeValue
 		floatVal, err := strconv.ParseFloat(fillmode, 64)
 		if err != nil {
 			return fmt.Errorf("error parsing fill value %v", fillmode)
@@ -0,0 +1,157 @@
+package sqleng
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SAP/go-hdb/driver"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// validateRequest is the body of a validation request. From and to are epoch
+// milliseconds, the last hour is validated if they are not set.
+type validateRequest struct {
+	Query         json.RawMessage `json:"query"`
+	From          int64           `json:"from"`
+	To            int64           `json:"to"`
+	IntervalMs    int64           `json:"intervalMs"`
+	MaxDataPoints int64           `json:"maxDataPoints"`
+}
+
+// validateColumn is a result column of a validated query.
+type validateColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// validateError describes why a query is invalid. Code, position, line and column are set
+// for errors reported by HANA, line and column count from 1.
+type validateError struct {
+	Message  string `json:"message"`
+	Stage    string `json:"stage"`
+	Code     int    `json:"code,omitempty"`
+	Position int    `json:"position,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// validateResponse is the result of a validation. SQL is the statement built so far.
+type validateResponse struct {
+	Valid   bool             `json:"valid"`
+	SQL     string           `json:"sql"`
+	Columns []validateColumn `json:"columns,omitempty"`
+	Error   *validateError   `json:"error,omitempty"`
+}
+
+// sqlErrorLocation returns the line and column of a HANA error position, the offset of
+// the erroneous character in the statement.
+func sqlErrorLocation(sql string, position int) (int, int) {
+	line, column := 1, 1
+	for i, r := range []rune(sql) {
+		if i >= position {
+			break
+		}
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
+// validateHandler runs a query through the interpolation pipeline and prepares the result
+// on HANA without executing it. An invalid query is reported in the response body.
+func (e *DataSourceHandler) validateHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeResourceError(rw, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	var req validateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeResourceError(rw, http.StatusBadRequest, fmt.Errorf("invalid validation request: %w", err))
+		return
+	}
+	queryJson, err := ParseQueryJson(req.Query)
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, err)
+		return
+	}
+	if queryJson.RawSql == "" && !queryJson.structured() {
+		writeResourceError(rw, http.StatusBadRequest, errors.New("the query has no SQL"))
+		return
+	}
+
+	to := time.Now()
+	if req.To > 0 {
+		to = time.UnixMilli(req.To)
+	}
+	from := to.Add(-time.Hour)
+	if req.From > 0 {
+		from = time.UnixMilli(req.From)
+	}
+	query := backend.DataQuery{
+		RefID:         "validate",
+		JSON:          req.Query,
+		TimeRange:     backend.TimeRange{From: from, To: to},
+		Interval:      time.Duration(req.IntervalMs) * time.Millisecond,
+		MaxDataPoints: req.MaxDataPoints,
+	}
+
+	res := validateResponse{}
+	logger := e.log.FromContext(r.Context())
+	sql, err := e.buildQuery(r.Context(), &query, queryJson, userOrigin(backend.UserFromContext(r.Context())))
+	if err != nil {
+		var buildErr *queryBuildError
+		if errors.As(err, &buildErr) {
+			res.SQL = buildErr.sql
+			res.Error = &validateError{Stage: buildErr.stage, Message: e.TransformQueryError(logger, buildErr.err).Error()}
+		} else {
+			res.Error = &validateError{Stage: "building query failed", Message: err.Error()}
+		}
+		writeValidateResponse(rw, res)
+		return
+	}
+	res.SQL = sql
+
+	var metadata driver.StmtMetadata
+	stmt, err := e.db.PrepareContext(driver.WithStmtMetadata(r.Context(), &metadata), sql)
+	if err != nil {
+		res.Error = &validateError{Stage: "prepare failed", Message: e.TransformQueryError(logger, err).Error()}
+		var dbErr driver.Error
+		if errors.As(err, &dbErr) {
+			res.Error.Code = dbErr.Code()
+			res.Error.Position = dbErr.Position()
+			res.Error.Line, res.Error.Column = sqlErrorLocation(sql, dbErr.Position())
+		}
+		writeValidateResponse(rw, res)
+		return
+	}
+	if err := stmt.Close(); err != nil {
+		logger.Warn("Failed to close statement", "err", err)
+	}
+
+	res.Valid = true
+	if metadata != nil {
+		for _, c := range metadata.ColumnTypes() {
+			nullable, _ := c.Nullable()
+			res.Columns = append(res.Columns, validateColumn{Name: c.Name(), Type: strings.ToUpper(c.DatabaseTypeName()), Nullable: nullable})
+		}
+	}
+	writeValidateResponse(rw, res)
+}
+
+func writeValidateResponse(rw http.ResponseWriter, res validateResponse) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		writeResourceError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeResourceJSON(rw, body)
+}
@@ -0,0 +1,63 @@
+package sqleng
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
+)
+
+type failingMacroEngine struct{}
+
+type plainErrorTransformer struct{}
+
+func (plainErrorTransformer) TransformQueryError(_ log.Logger, err error) error { return err }
+
+func (plainErrorTransformer) GetConverterList() []sqlutil.Converter { return nil }
+
+func (plainErrorTransformer) GetConverterList2(*ConverterOptions) []sqlutil.Converter { return nil }
+
+func (failingMacroEngine) Interpolate(_ *backend.DataQuery, _ backend.TimeRange, sql string) (string, error) {
+	return sql, errors.New("unknown macro $__bad")
+}
+
+func TestSQLErrorLocation(t *testing.T) {
+	sql := "SELECT A,\n  B FRM T"
+	// HANA reports the offset of FRM
+	line, column := sqlErrorLocation(sql, 14)
+	if line != 2 || column != 5 {
+		t.Fatalf("got line %d column %d", line, column)
+	}
+}
+
+func TestValidateInterpolationError(t *testing.T) {
+	handler, err := NewQueryDataHandler("error", nil, DataPluginConfiguration{}, plainErrorTransformer{}, failingMacroEngine{}, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"query":{"rawSql":"SELECT $__bad(A) FROM T","format":"table"},"from":1704067200000,"to":1704070800000}`)
+	var res *backend.CallResourceResponse
+	err = handler.CallResource(context.Background(), &backend.CallResourceRequest{Method: http.MethodPost, Path: "validate", URL: "validate", Body: body},
+		backend.CallResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+			res = r
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res == nil || res.Status != http.StatusOK {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	var got validateResponse
+	if err := json.Unmarshal(res.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Valid || got.Error == nil || got.Error.Stage != "interpolation failed" || got.SQL != "SELECT $__bad(A) FROM T" {
+		t.Fatalf("unexpected validation result %+v", got)
+	}
+}
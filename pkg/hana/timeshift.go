@@ -0,0 +1,96 @@
+package hana
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
+	"github.com/vincent/sap-hanadb/pkg/hana/sqleng"
+)
+
+// calendarShiftPattern matches shifts in years or months, which are applied on the calendar.
+var calendarShiftPattern = regexp.MustCompile(`^(\d+)(y|M)$`)
+
+// timeShift moves a time back by a shift like '7d' or '1y'. Years and months keep the
+// day of the month, all other units are fixed durations.
+type timeShift func(time.Time) time.Time
+
+func parseTimeShift(s string) (timeShift, error) {
+	s = strings.Trim(s, `'"`)
+	if m := calendarShiftPattern.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+		if m[2] == "y" {
+			return func(t time.Time) time.Time { return t.AddDate(-n, 0, 0) }, nil
+		}
+		return func(t time.Time) time.Time { return t.AddDate(0, -n, 0) }, nil
+	}
+	d, err := gtime.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("error parsing time shift %v", s)
+	}
+	return func(t time.Time) time.Time { return t.Add(-d) }, nil
+}
+
+// timeShiftMacro expands the macros querying a shifted time range:
+//
+//	$__timeFilterShift(col, shift)          the time filter of the shifted range
+//	$__timeFromShift(shift)                 the start of the shifted range
+//	$__timeToShift(shift)                   the end of the shifted range
+//	$__timeShiftGroup(col, interval, shift) a time group moved forward by the shift, so the
+//	                                        shifted series plots on the current range
+//
+// The offset used to realign the series is the shift at the start of the range.
+func (m *hanaMacroEngine) timeShiftMacro(timeRange backend.TimeRange, query *backend.DataQuery, name string, args []string) (string, error) {
+	var shiftArg string
+	switch name {
+	case "__timeFromShift", "__timeToShift":
+		if len(args) != 1 || args[0] == "" {
+			return "", fmt.Errorf("macro %v needs a time shift", name)
+		}
+		shiftArg = args[0]
+	case "__timeFilterShift":
+		if len(args) != 2 {
+			return "", fmt.Errorf("macro %v needs time column and time shift", name)
+		}
+		shiftArg = args[1]
+	default:
+		if len(args) < 3 {
+			return "", fmt.Errorf("macro %v needs time column, interval, time shift and optional fill value", name)
+		}
+		shiftArg = args[2]
+	}
+	shift, err := parseTimeShift(shiftArg)
+	if err != nil {
+		return "", err
+	}
+	from, to := shift(timeRange.From.UTC()), shift(timeRange.To.UTC())
+
+	switch name {
+	case "__timeFromShift":
+		return fmt.Sprintf("FROM_UNIXTIME(%d)", from.Unix()), nil
+	case "__timeToShift":
+		return fmt.Sprintf("FROM_UNIXTIME(%d)", to.Unix()), nil
+	case "__timeFilterShift":
+		return fmt.Sprintf("%s BETWEEN FROM_UNIXTIME(%d) AND FROM_UNIXTIME(%d)", args[0], from.Unix(), to.Unix()), nil
+	}
+
+	interval, err := gtime.ParseInterval(strings.Trim(args[1], `'"`))
+	if err != nil {
+		return "", fmt.Errorf("error parsing interval %v", args[1])
+	}
+	if len(args) == 4 {
+		if err := sqleng.SetupFillmode(query, interval, args[3]); err != nil {
+			return "", err
+		}
+	}
+	offset := timeRange.From.UTC().Unix() - from.Unix()
+	epoch := fmt.Sprintf("(UNIX_TIMESTAMP(%s) + %d)", args[0], offset)
+	return m.groupExpr(epoch, timeRange, fmt.Sprintf("%.0f", interval.Seconds())), nil
+}
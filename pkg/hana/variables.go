@@ -0,0 +1,169 @@
+package hana
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// allValuesToken is sent by the frontend in place of the values when All is selected.
+	allValuesToken = "$__all"
+	// maxInListSize is the number of values per IN list; longer lists are split and combined with OR.
+	maxInListSize = 1000
+)
+
+var (
+	valueMacroPattern = regexp.MustCompile(`\$(__in|__like|__optionalFilter)\(`)
+	// numberLiteralPattern matches the numbers HANA reads as numeric literal, unlike Inf, NaN or 0x1p-2.
+	numberLiteralPattern = regexp.MustCompile(`^[+-]?\d+(\.\d+)?([eE][+-]?\d+)?$`)
+)
+
+// variableValue is a single value of a template variable.
+type variableValue struct {
+	text   string
+	quoted bool
+}
+
+// literal renders the value as HANA literal. Quoted values become unicode string literals,
+// unquoted decimal numbers are kept as numbers.
+func (v variableValue) literal() string {
+	if !v.quoted && numberLiteralPattern.MatchString(v.text) {
+		return v.text
+	}
+	return "N'" + strings.ReplaceAll(v.text, "'", "''") + "'"
+}
+
+// scanMacroArgs splits the arguments of a macro call starting after the opening parenthesis.
+// Commas and parentheses inside string literals or nested calls are part of the argument.
+// It returns the raw arguments and the index after the closing parenthesis.
+func scanMacroArgs(sql string, start int) ([]string, int, error) {
+	var args []string
+	depth := 0
+	quote := byte(0)
+	argStart := start
+	for i := start; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				// a doubled quote is an escaped quote
+				if i+1 < len(sql) && sql[i+1] == quote {
+					i++
+				} else {
+					quote = 0
+				}
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ')':
+			args = append(args, strings.TrimSpace(sql[argStart:i]))
+			return args, i + 1, nil
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(sql[argStart:i]))
+			argStart = i + 1
+		}
+	}
+	return nil, 0, fmt.Errorf("missing closing parenthesis for macro call")
+}
+
+// parseVariableValues reads the values of a variable rendered as quoted literals. It
+// returns all=true if All is selected.
+func parseVariableValues(args []string) (values []variableValue, all bool, err error) {
+	for _, arg := range args {
+		switch {
+		case arg == "":
+		case arg == allValuesToken:
+			all = true
+		case len(arg) >= 2 && arg[0] == '\'' && arg[len(arg)-1] == '\'',
+			len(arg) >= 3 && (arg[0] == 'N' || arg[0] == 'n') && arg[1] == '\'' && arg[len(arg)-1] == '\'':
+			text := arg[strings.IndexByte(arg, '\'')+1 : len(arg)-1]
+			values = append(values, variableValue{text: strings.ReplaceAll(text, "''", "'"), quoted: true})
+		case strings.ContainsAny(arg, "' ;"):
+			return nil, false, fmt.Errorf("invalid variable value %s", arg)
+		default:
+			values = append(values, variableValue{text: arg})
+		}
+	}
+	return values, all, nil
+}
+
+// inList renders col IN (...) and splits long lists into several IN lists.
+func inList(col string, values []variableValue) string {
+	var parts []string
+	for start := 0; start < len(values); start += maxInListSize {
+		end := start + maxInListSize
+		if end > len(values) {
+			end = len(values)
+		}
+		literals := make([]string, 0, end-start)
+		for _, v := range values[start:end] {
+			literals = append(literals, v.literal())
+		}
+		parts = append(parts, fmt.Sprintf("%s IN (%s)", col, strings.Join(literals, ", ")))
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+// valueMacro renders $__in, $__like and $__optionalFilter. All of them collapse to 1=1 if
+// All or no value is selected.
+func valueMacro(name string, args []string) (string, error) {
+	if len(args) < 1 || args[0] == "" {
+		return "", fmt.Errorf("macro %v needs a column and a variable", name)
+	}
+	col := args[0]
+	values, all, err := parseVariableValues(args[1:])
+	if err != nil {
+		return "", err
+	}
+	if all || len(values) == 0 {
+		return "1=1", nil
+	}
+	switch name {
+	case "__like":
+		conditions := make([]string, len(values))
+		for i, v := range values {
+			conditions[i] = fmt.Sprintf("%s LIKE %s", col, v.literal())
+		}
+		if len(conditions) == 1 {
+			return conditions[0], nil
+		}
+		return "(" + strings.Join(conditions, " OR ") + ")", nil
+	case "__optionalFilter":
+		if len(values) == 1 {
+			return fmt.Sprintf("%s = %s", col, values[0].literal()), nil
+		}
+	}
+	return inList(col, values), nil
+}
+
+// interpolateValueMacros expands the template variable macros. They are expanded before
+// the other macros, because their values may contain commas and parentheses.
+func interpolateValueMacros(sql string) (string, error) {
+	var sb strings.Builder
+	for {
+		loc := valueMacroPattern.FindStringSubmatchIndex(sql)
+		if loc == nil {
+			sb.WriteString(sql)
+			return sb.String(), nil
+		}
+		args, end, err := scanMacroArgs(sql, loc[1])
+		if err != nil {
+			return "", err
+		}
+		res, err := valueMacro(sql[loc[2]:loc[3]], args)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(sql[:loc[0]])
+		sb.WriteString(res)
+		sql = sql[end:]
+	}
+}
@@ -0,0 +1,68 @@
+package hana
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInterpolateValueMacros(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{
+			sql:  "WHERE $__in(PLANT, '1000','2000') AND X = 1",
+			want: "WHERE PLANT IN (N'1000', N'2000') AND X = 1",
+		},
+		{
+			sql:  "WHERE $__in(NAME, 'O''Brien','a, (b)')",
+			want: "WHERE NAME IN (N'O''Brien', N'a, (b)')",
+		},
+		{
+			sql:  "WHERE $__in(QTY, 1,2)",
+			want: "WHERE QTY IN (1, 2)",
+		},
+		{
+			sql:  "WHERE $__in(C, Inf, NaN, 0x1p-2, -1.5e3)",
+			want: "WHERE C IN (N'Inf', N'NaN', N'0x1p-2', -1.5e3)",
+		},
+		{
+			sql:  "WHERE $__in(PLANT, $__all) AND $__optionalFilter(PLANT, )",
+			want: "WHERE 1=1 AND 1=1",
+		},
+		{
+			sql:  "WHERE $__optionalFilter(UPPER(PLANT), 'Köln')",
+			want: "WHERE UPPER(PLANT) = N'Köln'",
+		},
+		{
+			sql:  "WHERE $__like(MATERIAL, 'A%','B%')",
+			want: "WHERE (MATERIAL LIKE N'A%' OR MATERIAL LIKE N'B%')",
+		},
+	}
+	for _, tt := range tests {
+		got, err := interpolateValueMacros(tt.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Fatalf("got %q, want %q", got, tt.want)
+		}
+	}
+
+	for _, sql := range []string{"$__in(PLANT, 'a'", "$__in(PLANT, 1; DROP TABLE T)", "$__in()"} {
+		if _, err := interpolateValueMacros(sql); err == nil {
+			t.Fatalf("expected an error for %q", sql)
+		}
+	}
+}
+
+func TestInListSplit(t *testing.T) {
+	values := make([]variableValue, maxInListSize+1)
+	for i := range values {
+		values[i] = variableValue{text: "1"}
+	}
+	got := inList("ID", values)
+	if strings.Count(got, "ID IN (") != 2 || !strings.HasPrefix(got, "(ID IN (") {
+		t.Fatalf("list not split: %.60s", got)
+	}
+}
@@ -1,17 +1,38 @@
-import { v4 as uuidv4 } from 'uuid';
-
-import { DataSourceInstanceSettings, TimeRange } from '@grafana/data';
+import {
+  AdHocVariableFilter,
+  DataSourceGetTagKeysOptions,
+  DataSourceInstanceSettings,
+  DataSourceWithSupplementaryQueriesSupport,
+  MetricFindValue,
+  ScopedVars,
+  SupplementaryQueryOptions,
+  SupplementaryQueryType,
+  TimeRange,
+} from '@grafana/data';
 import { CompletionItemKind, LanguageDefinition, TableIdentifier } from '@grafana/experimental';
 // import { config } from '@grafana/runtime';
-import { COMMON_FNS, DB, FuncParameter, MACRO_FUNCTIONS, SQLQuery, SqlDatasource, formatSQL } from 'grafana-sql';
-
+import {
+  COMMON_FNS,
+  DB,
+  FuncParameter,
+  MACRO_FUNCTIONS,
+  QueryFormat,
+  SQLQuery,
+  SqlDatasource,
+  ValidationResults,
+  formatSQL,
+} from 'grafana-sql';
+
+import { toBuilderQuery } from './builderQuery';
 import { mapFieldsToTypes } from './fields';
-import { buildColumnQuery, buildTableQuery, showDatabases } from './hanaMetaQuery';
 import { getSqlCompletionProvider } from './sqlCompletionProvider';
-import { quoteIdentifierIfNecessary, quoteLiteral, toRawSql } from './sqlUtil';
-import { HANAOptions } from './types';
+import { quoteIdentifierIfNecessary, quoteLiteral, toRawSql, unquoteIdentifier } from './sqlUtil';
+import { CalcViewParameter, CalcViewSemantics, CatalogResponse, HANAOptions, ValidateResponse } from './types';
 
-export class SapHanaDatasource extends SqlDatasource {
+export class SapHanaDatasource
+  extends SqlDatasource
+  implements DataSourceWithSupplementaryQueriesSupport<SQLQuery>
+{
   sqlLanguageDefinition: LanguageDefinition | undefined;
 
   constructor(private instanceSettings: DataSourceInstanceSettings<HANAOptions>) {
@@ -22,6 +43,52 @@ export class SapHanaDatasource extends SqlDatasource {
     return { quoteLiteral };
   }
 
+  // Queries of the visual editor are compiled by the backend, which quotes and checks the columns.
+  applyTemplateVariables(target: SQLQuery, scopedVars: ScopedVars, filters?: AdHocVariableFilter[]) {
+    const query = super.applyTemplateVariables(target, scopedVars, filters);
+    const builder = toBuilderQuery(target);
+    if (!builder) {
+      return query;
+    }
+    const replace = (value: string) => this.templateSrv.replace(value, scopedVars, 'csv');
+    return {
+      ...query,
+      builder: {
+        ...builder,
+        where: builder.where?.map((c) => ({
+          ...c,
+          value: c.value && replace(c.value),
+          values: c.values?.flatMap((v) => replace(v).split(',')),
+        })),
+      },
+    };
+  }
+
+  // Ad hoc filters apply to the result columns of each query. The keys offered are the columns of the tables
+  // of the visual editor queries, other keys are entered freely and validated by the backend. Values are
+  // entered freely, the catalog does not list them.
+  async getTagKeys(options?: DataSourceGetTagKeysOptions<SQLQuery>): Promise<MetricFindValue[]> {
+    const defaultSchema = this.instanceSettings.jsonData.defaultSchema;
+    const tables = (options?.queries ?? []).filter((q) => q.table);
+    const fields = await Promise.all(
+      tables.map((q) => this.fetchFields({ dataset: q.dataset || defaultSchema, table: q.table }))
+    );
+    const names = new Set(fields.flat().map((f) => f.name));
+    return [...names].sort().map((text) => ({ text }));
+  }
+
+  getSupportedSupplementaryQueryTypes(): SupplementaryQueryType[] {
+    return [SupplementaryQueryType.LogsVolume];
+  }
+
+  // The logs volume runs the logs query again, the backend counts the rows per interval and level.
+  getSupplementaryQuery(options: SupplementaryQueryOptions, query: SQLQuery): SQLQuery | undefined {
+    if (options.type !== SupplementaryQueryType.LogsVolume || query.format !== QueryFormat.Logs) {
+      return undefined;
+    }
+    return { ...query, refId: `log-volume-${query.refId}`, format: QueryFormat.LogsVolume };
+  }
+
   getSqlLanguageDefinition(): LanguageDefinition {
     if (this.sqlLanguageDefinition !== undefined) {
       return this.sqlLanguageDefinition;
@@ -40,28 +107,76 @@ export class SapHanaDatasource extends SqlDatasource {
     return this.sqlLanguageDefinition;
   }
 
+  // The catalog is listed by the backend, which caches the listings and defaults to the configured schema.
   async fetchDatasets(): Promise<string[]> {
-    const datasets = await this.runSql<string[]>(showDatabases(), { refId: 'datasets' });
-    return datasets.map((t) => quoteIdentifierIfNecessary(t[0]));
+    const res = await this.getResource<CatalogResponse>('schemas');
+    return res.items.map((t) => quoteIdentifierIfNecessary(t.name));
   }
 
   async fetchTables(dataset?: string, table?: string): Promise<string[]> {
-    const tables = await this.runSql<string[]>(buildTableQuery(dataset, table), { refId: 'tables' });
-    return tables.map((t) => quoteIdentifierIfNecessary(t[0]));
+    const params: Record<string, string> = {};
+    if (dataset) {
+      params.schema = unquoteIdentifier(dataset);
+    }
+    if (table) {
+      params.filter = table;
+    }
+    const [tables, views] = await Promise.all([
+      this.getResource<CatalogResponse>('tables', params),
+      this.getResource<CatalogResponse>('views', params),
+    ]);
+    return [...tables.items, ...views.items].map((t) => quoteIdentifierIfNecessary(t.name)).sort();
+  }
+
+  async fetchCalcViewParameters(view: string, schema?: string): Promise<CalcViewParameter[]> {
+    const params: Record<string, string> = { view };
+    if (schema) {
+      params.schema = unquoteIdentifier(schema);
+    }
+    const res = await this.getResource<{ parameters: CalcViewParameter[] }>('calculation-views/parameters', params);
+    return res.parameters;
+  }
+
+  async fetchCalcViewSemantics(view: string, schema?: string): Promise<CalcViewSemantics> {
+    const params: Record<string, string> = { view };
+    if (schema) {
+      params.schema = unquoteIdentifier(schema);
+    }
+    return this.getResource<CalcViewSemantics>('calculation-views/semantics', params);
+  }
+
+  // The backend interpolates the query and prepares it on HANA without executing it.
+  async validateQuery(query: SQLQuery, range?: TimeRange): Promise<ValidationResults> {
+    const target = this.applyTemplateVariables(query, {});
+    const res = await this.postResource<ValidateResponse>('validate', {
+      query: target,
+      from: range?.from.valueOf(),
+      to: range?.to.valueOf(),
+    });
+    if (res.valid) {
+      return { query, rawSql: res.sql, error: '', isError: false, isValid: true };
+    }
+    const location = res.error?.line ? ` (line ${res.error.line}, column ${res.error.column})` : '';
+    const error = `${res.error?.message ?? 'invalid query'}${location}`;
+    return { query, rawSql: res.sql, error, isError: true, isValid: false };
   }
 
   async fetchFields(query: Partial<SQLQuery>) {
     if (!query.dataset || !query.table) {
       return [];
     }
-    const queryString = buildColumnQuery(query.table, query.dataset);
-    const frame = await this.runSql<string[]>(queryString, { refId: `fields-${uuidv4()}` });
-    const fields = frame.map((f) => ({
-      name: f[0],
-      text: f[0],
-      value: quoteIdentifierIfNecessary(f[0]),
-      type: f[1],
-      label: f[0],
+    // the table may be qualified by its schema
+    const parts = query.table.includes('.') ? query.table.split('.') : [query.dataset, query.table];
+    const res = await this.getResource<CatalogResponse>('columns', {
+      schema: unquoteIdentifier(parts[0]),
+      table: unquoteIdentifier(parts[1]),
+    });
+    const fields = res.items.map((f) => ({
+      name: f.name,
+      text: f.name,
+      value: quoteIdentifierIfNecessary(f.name),
+      type: f.type,
+      label: f.name,
     }));
     return mapFieldsToTypes(fields);
   }
@@ -115,8 +230,7 @@ export class SapHanaDatasource extends SqlDatasource {
       datasets: () => this.fetchDatasets(),
       tables: (dataset?: string, table?: string) => this.fetchTables(dataset, table),
       fields: (query: SQLQuery) => this.fetchFields(query),
-      validateQuery: (query: SQLQuery, _range?: TimeRange) =>
-        Promise.resolve({ query, error: '', isError: false, isValid: true }),
+      validateQuery: (query: SQLQuery, range?: TimeRange) => this.validateQuery(query, range),
       dsID: () => this.id,
       toRawSql,
       functions: () => this.getFunctions(),
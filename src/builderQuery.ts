@@ -0,0 +1,112 @@
+import { EditorMode } from '@grafana/experimental';
+import { JsonGroup, JsonItem, JsonTree } from '@react-awesome-query-builder/ui';
+import { SQLQuery } from 'grafana-sql';
+
+import { unquoteIdentifier } from './sqlUtil';
+import { BuilderCondition, BuilderQuery } from './types';
+
+// operators of the query builder mapped to the operators compiled by the backend
+const operators: Record<string, string> = {
+  equal: '=',
+  not_equal: '<>',
+  less: '<',
+  less_or_equal: '<=',
+  greater: '>',
+  greater_or_equal: '>=',
+  like: 'LIKE',
+  not_like: 'NOT LIKE',
+  starts_with: 'LIKE',
+  ends_with: 'LIKE',
+  between: 'BETWEEN',
+  is_null: 'IS NULL',
+  is_not_null: 'IS NOT NULL',
+  select_any_in: 'IN',
+  select_not_any_in: 'NOT IN',
+};
+
+function children(group: JsonGroup | JsonTree): JsonItem[] {
+  const items = group.children1 ?? [];
+  return Array.isArray(items) ? items : Object.values(items);
+}
+
+function toCondition(item: JsonItem): BuilderCondition | undefined {
+  if (item.type !== 'rule' || !item.properties?.field || !item.properties.operator) {
+    return undefined;
+  }
+  const { field, operator } = item.properties;
+  const values: string[] = (item.properties.value ?? []).filter((v: unknown) => v !== undefined).map(String);
+  const column = unquoteIdentifier(String(field));
+  if (operator === 'macros') {
+    return values[0] === 'timeFilter' ? { column, operator: '$__timeFilter' } : undefined;
+  }
+  const op = operators[operator];
+  if (!op) {
+    return undefined;
+  }
+  switch (operator) {
+    case 'like':
+    case 'not_like':
+      return { column, operator: op, value: `%${values[0] ?? ''}%` };
+    case 'starts_with':
+      return { column, operator: op, value: `${values[0] ?? ''}%` };
+    case 'ends_with':
+      return { column, operator: op, value: `%${values[0] ?? ''}` };
+    case 'between':
+    case 'select_any_in':
+    case 'select_not_any_in':
+      return { column, operator: op, values: values.flatMap((v) => v.split(',')) };
+  }
+  return { column, operator: op, value: values[0] };
+}
+
+/**
+ * Converts a query of the visual editor into the builder model compiled by the backend.
+ * Returns undefined if the query uses parts the backend does not compile, the query then
+ * runs the SQL generated by the editor.
+ */
+export function toBuilderQuery(query: SQLQuery): BuilderQuery | undefined {
+  const sql = query.sql;
+  if (query.editorMode !== EditorMode.Builder || !sql || !query.table) {
+    return undefined;
+  }
+  const [schema, table] = query.table.includes('.') ? query.table.split('.') : [query.dataset, query.table];
+
+  const columns = (sql.columns ?? [])
+    .filter((c) => c.parameters?.[0]?.name)
+    .map((c) => ({
+      name: unquoteIdentifier(c.parameters![0].name!),
+      function: c.name || undefined,
+      parameters: c.parameters!.slice(1).map((p) => p.name ?? ''),
+      alias: c.alias ? unquoteIdentifier(c.alias) : undefined,
+    }));
+  if (!columns.length) {
+    return undefined;
+  }
+
+  let where: BuilderCondition[] = [];
+  let conjunction: string | undefined;
+  if (sql.whereJsonTree) {
+    const items = children(sql.whereJsonTree);
+    const conditions = items.map(toCondition);
+    if (conditions.some((c) => c === undefined) || sql.whereJsonTree.properties?.not) {
+      return undefined;
+    }
+    where = conditions as BuilderCondition[];
+    conjunction = sql.whereJsonTree.properties?.conjunction;
+  } else if (sql.whereString) {
+    return undefined;
+  }
+
+  return {
+    schema: schema ? unquoteIdentifier(schema) : undefined,
+    table: unquoteIdentifier(table!),
+    columns,
+    where,
+    conjunction,
+    groupBy: (sql.groupBy ?? []).filter((g) => g.property.name).map((g) => unquoteIdentifier(g.property.name!)),
+    orderBy: sql.orderBy?.property.name
+      ? [{ column: unquoteIdentifier(sql.orderBy.property.name), descending: sql.orderByDirection === 'DESC' }]
+      : undefined,
+    limit: sql.limit,
+  };
+}
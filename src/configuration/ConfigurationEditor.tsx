@@ -18,11 +18,13 @@ import {
   Label,
   SecretInput,
   SecureSocksProxySettings,
+  Select,
   Switch,
   Tooltip,
 } from '@grafana/ui';
 
 import { HANAOptions } from '../types';
+import { RowScopesEditor } from './RowScopesEditor';
 
 export const ConfigurationEditor = (props: DataSourcePluginOptionsEditorProps<HANAOptions>) => {
   const [isOpen, setIsOpen] = useState(true);
@@ -48,8 +50,33 @@ export const ConfigurationEditor = (props: DataSourcePluginOptionsEditorProps<HA
     };
   };
 
+  const onNumberChanged = (property: keyof HANAOptions) => {
+    return (event: SyntheticEvent<HTMLInputElement>) => {
+      const value = event.currentTarget.value;
+      updateDatasourcePluginJsonDataOption(props, property, value === '' ? undefined : Number(value));
+    };
+  };
+
+  // lists are edited as comma separated values
+  const onListChanged = (property: keyof HANAOptions) => {
+    return (event: SyntheticEvent<HTMLInputElement>) => {
+      const values = event.currentTarget.value
+        .split(',')
+        .map((v) => v.trim())
+        .filter((v) => v !== '');
+      updateDatasourcePluginJsonDataOption(props, property, values.length ? values : undefined);
+    };
+  };
+
   const WIDTH_LONG = 40;
 
+  const decimalModeOptions = [
+    { label: 'float64', value: 'float64', description: 'Convert decimals to floating point numbers' },
+    { label: 'int64', value: 'int64', description: 'Use integers for decimals with scale 0' },
+    { label: 'scaled', value: 'scaled', description: 'Integers multiplied by 10^scale' },
+    { label: 'string', value: 'string', description: 'Exact decimal strings' },
+  ];
+
   return (
     <>
       <DataSourceDescription
@@ -235,6 +262,162 @@ export const ConfigurationEditor = (props: DataSourcePluginOptionsEditorProps<HA
               onChange={onUpdateDatasourceJsonDataOption(props, 'timeInterval')}
             />
           </Field>
+
+          <Field
+            label="Max frame size (bytes)"
+            description="Approximate memory budget of a single query result. Scanning stops with a notice once it is reached. Set to 0 to disable."
+          >
+            <Input
+              width={WIDTH_LONG}
+              type="number"
+              placeholder="268435456"
+              value={jsonData.maxFrameBytes ?? ''}
+              onChange={onNumberChanged('maxFrameBytes')}
+            />
+          </Field>
+
+          <Field
+            label="Max LOB length (bytes)"
+            description="Maximum number of bytes read per CLOB, NCLOB or BLOB value. Longer values are truncated and marked. Set to 0 to disable."
+          >
+            <Input
+              width={WIDTH_LONG}
+              type="number"
+              placeholder="65536"
+              value={jsonData.lobMaxLength ?? ''}
+              onChange={onNumberChanged('lobMaxLength')}
+            />
+          </Field>
+
+          <Field
+            label="Metadata cache (seconds)"
+            description="How long schema, table and column listings are cached. Set to 0 to disable."
+          >
+            <Input
+              width={WIDTH_LONG}
+              type="number"
+              placeholder="300"
+              value={jsonData.metadataCacheTTL ?? ''}
+              onChange={onNumberChanged('metadataCacheTTL')}
+            />
+          </Field>
+
+          <Field
+            label="Decimal mode"
+            description="How DECIMAL columns are returned. Queries can override this with the decimalMode property."
+          >
+            <Select
+              width={WIDTH_LONG}
+              options={decimalModeOptions}
+              value={jsonData.decimalMode || 'float64'}
+              onChange={(v) => updateDatasourcePluginJsonDataOption(props, 'decimalMode', v.value)}
+            />
+          </Field>
+        </ConfigSubSection>
+
+        <ConfigSubSection
+          title="Query cost guard"
+          description="Explain queries before running them and reject those whose estimates exceed the limits."
+        >
+          <Field
+            label="Max estimated cost"
+            description="Maximum estimated cost of the plan of a query. Leave empty to disable."
+          >
+            <Input
+              width={WIDTH_LONG}
+              type="number"
+              value={jsonData.maxEstimatedCost ?? ''}
+              onChange={onNumberChanged('maxEstimatedCost')}
+            />
+          </Field>
+
+          <Field
+            label="Max estimated rows"
+            description="Maximum estimated output size of any operator of the plan, for example of a table scan. Leave empty to disable."
+          >
+            <Input
+              width={WIDTH_LONG}
+              type="number"
+              value={jsonData.maxEstimatedRows ?? ''}
+              onChange={onNumberChanged('maxEstimatedRows')}
+            />
+          </Field>
+
+          <Field
+            label="Exempt dashboards"
+            description="Comma separated UIDs of dashboards whose queries are not checked. The dashboard is identified by a request header, which any user sending queries to the data source API can set, so this is not a security boundary. Exempt users instead where the limits must hold."
+          >
+            <Input
+              width={WIDTH_LONG}
+              defaultValue={jsonData.costGuardExemptDashboards?.join(', ') ?? ''}
+              onBlur={onListChanged('costGuardExemptDashboards')}
+            />
+          </Field>
+
+          <Field label="Exempt users" description="Comma separated logins of users whose queries are not checked.">
+            <Input
+              width={WIDTH_LONG}
+              defaultValue={jsonData.costGuardExemptUsers?.join(', ') ?? ''}
+              onBlur={onListChanged('costGuardExemptUsers')}
+            />
+          </Field>
+        </ConfigSubSection>
+
+        <ConfigSubSection
+          title="Query governance"
+          description="Limits enforced on every query of this data source, in addition to the permissions of the database user."
+        >
+          <Field
+            label="Allowed schemas"
+            description="Comma separated schemas queries may read. Leave both lists empty to allow all schemas."
+          >
+            <Input
+              width={WIDTH_LONG}
+              defaultValue={jsonData.allowedSchemas?.join(', ') ?? ''}
+              onBlur={onListChanged('allowedSchemas')}
+            />
+          </Field>
+
+          <Field label="Allowed tables" description="Comma separated tables outside of the allowed schemas, as SCHEMA.TABLE.">
+            <Input
+              width={WIDTH_LONG}
+              defaultValue={jsonData.allowedTables?.join(', ') ?? ''}
+              onBlur={onListChanged('allowedTables')}
+            />
+          </Field>
+
+          <Field label="Max time range" description="Longest time range a query may cover, for example 90d.">
+            <Input
+              width={WIDTH_LONG}
+              placeholder="unlimited"
+              value={jsonData.maxTimeRange || ''}
+              onChange={onUpdateDatasourceJsonDataOption(props, 'maxTimeRange')}
+            />
+          </Field>
+
+          <Field
+            label="Min refresh interval"
+            description="Shortest interval at which the same query may be refreshed, for example 1m. Alert rules are not limited."
+          >
+            <Input
+              width={WIDTH_LONG}
+              placeholder="unlimited"
+              value={jsonData.minRefreshInterval || ''}
+              onChange={onUpdateDatasourceJsonDataOption(props, 'minRefreshInterval')}
+            />
+          </Field>
+        </ConfigSubSection>
+
+        <ConfigSubSection
+          title="Row scoping"
+          description="Every table with a scoped column is filtered to the values of the scopes matching the user, for example by MANDT or BUKRS. Users without a matching scope cannot read these tables, which includes alert rules without a user."
+        >
+          <RowScopesEditor
+            scopes={jsonData.rowScopes ?? []}
+            onChange={(scopes) =>
+              updateDatasourcePluginJsonDataOption(props, 'rowScopes', scopes.length ? scopes : undefined)
+            }
+          />
         </ConfigSubSection>
 
         <ConnectionLimits options={options} onOptionsChange={onOptionsChange} />
@@ -0,0 +1,76 @@
+import { Button, IconButton, InlineField, InlineFieldRow, Input, Stack } from '@grafana/ui';
+
+import { RowScope } from '../types';
+
+interface Props {
+  scopes: RowScope[];
+  onChange: (scopes: RowScope[]) => void;
+}
+
+const toList = (value: string) =>
+  value
+    .split(',')
+    .map((v) => v.trim())
+    .filter((v) => v !== '');
+
+// RowScopesEditor edits the row scopes of the data source, one row per scope. Lists are
+// edited as comma separated values.
+export const RowScopesEditor = ({ scopes, onChange }: Props) => {
+  const update = (index: number, scope: Partial<RowScope>) => {
+    onChange(scopes.map((s, i) => (i === index ? { ...s, ...scope } : s)));
+  };
+
+  return (
+    <Stack direction="column" gap={1}>
+      {scopes.map((scope, index) => (
+        // the inputs are uncontrolled, removing a scope remounts them with the remaining values
+        <InlineFieldRow key={`${scopes.length}-${index}`}>
+          <InlineField label="Users" tooltip="Logins or emails of users and team members">
+            <Input
+              width={24}
+              defaultValue={scope.users?.join(', ') ?? ''}
+              onBlur={(e) => update(index, { users: toList(e.currentTarget.value) })}
+            />
+          </InlineField>
+          <InlineField label="Roles" tooltip="Organization roles, for example Viewer">
+            <Input
+              width={16}
+              defaultValue={scope.roles?.join(', ') ?? ''}
+              onBlur={(e) => update(index, { roles: toList(e.currentTarget.value) })}
+            />
+          </InlineField>
+          <InlineField label="Column">
+            <Input
+              width={12}
+              placeholder="MANDT"
+              defaultValue={scope.column}
+              onBlur={(e) => update(index, { column: e.currentTarget.value.trim() })}
+            />
+          </InlineField>
+          <InlineField label="Values" tooltip="Allowed values, * allows all of them">
+            <Input
+              width={20}
+              defaultValue={scope.values.join(', ')}
+              onBlur={(e) => update(index, { values: toList(e.currentTarget.value) })}
+            />
+          </InlineField>
+          <IconButton
+            name="trash-alt"
+            aria-label="Remove row scope"
+            onClick={() => onChange(scopes.filter((_, i) => i !== index))}
+          />
+        </InlineFieldRow>
+      ))}
+      <div>
+        <Button
+          variant="secondary"
+          size="sm"
+          icon="plus"
+          onClick={() => onChange([...scopes, { column: '', values: [] }])}
+        >
+          Add row scope
+        </Button>
+      </div>
+    </Stack>
+  );
+};
@@ -222,6 +222,20 @@ export function QueryHeader({
           </>
         )}
 
+        <InlineSwitch
+          id={`sql-explain-${htmlId}`}
+          label="Explain"
+          transparent={true}
+          showLabel={true}
+          value={query.explain ?? false}
+          onChange={(ev) => {
+            if (!(ev.target instanceof HTMLInputElement)) {
+              return;
+            }
+            onChange({ ...query, explain: ev.target.checked || undefined });
+          }}
+        />
+
         <FlexItem grow={1} />
 
         {isQueryRunnable ? (
@@ -30,7 +30,7 @@ export function SelectRow({ query, onQueryChange, db, columns }: SelectRowProps)
 
   // Add necessary alias options for time series format
   // when that format has been selected
-  if (query.format === QueryFormat.Timeseries) {
+  if (query.format === QueryFormat.Timeseries || query.format === QueryFormat.TimeseriesMulti) {
     timeSeriesAliasOpts.push({ label: 'time', value: 'time' });
     timeSeriesAliasOpts.push({ label: 'value', value: 'value' });
   }
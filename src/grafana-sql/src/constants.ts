@@ -23,6 +23,9 @@ const fillParam: FuncParameter = {
       { label: '0', value: '0' },
       { label: 'NULL', value: 'NULL' },
       { label: 'previous', value: 'previous' },
+      { label: 'next', value: 'next' },
+      { label: 'linear', value: 'linear' },
+      { label: 'zero-after-last', value: 'zero-after-last' },
     ]),
 };
 
@@ -70,4 +73,15 @@ export const MACRO_NAMES = [
   '$__unixEpochNanoTo',
   '$__unixEpochGroup',
   '$__unixEpochGroupAlias',
+  '$__histogramBuckets',
+  '$__rate',
+  '$__increase',
+  '$__delta',
+  '$__timeFilterShift',
+  '$__timeFromShift',
+  '$__timeToShift',
+  '$__timeShiftGroup',
+  '$__in',
+  '$__like',
+  '$__optionalFilter',
 ];
@@ -2,6 +2,7 @@ import { lastValueFrom, Observable, throwError } from 'rxjs';
 import { map } from 'rxjs/operators';
 
 import {
+  AdHocVariableFilter,
   getDefaultTimeRange,
   DataFrame,
   DataFrameView,
@@ -32,11 +33,18 @@ import {
 import { ResponseParser } from '../ResponseParser';
 import { SqlQueryEditorLazy } from '../components/QueryEditorLazy';
 import { MACRO_NAMES } from '../constants';
-import { DB, SQLQuery, SQLOptions, SqlQueryModel, QueryFormat } from '../types';
+import { DB, SemanticQuery, SQLQuery, SQLOptions, SqlQueryModel, QueryFormat } from '../types';
 import migrateAnnotation from '../utils/migration';
 
 import { isSqlDatasourceDatabaseSelectionFeatureFlagEnabled } from './../components/QueryEditorFeatureFlag.utils';
 
+// ALL_VALUES is the value of a variable with All selected. It is passed on to the backend
+// macros, which expand it to a condition matching all rows.
+const ALL_VALUES = '$__all';
+// VALUE_MACRO_REGEX matches the variable argument of the macros expanded by interpolateValueMacros.
+const VALUE_MACRO_REGEX =
+  /(\$__(?:in|like|optionalFilter)\(\s*[^,()]+(?:\([^()]*\))?\s*,\s*)(\$\w+|\$\{[^}]+\}|\[\[[^\]]+\]\])(\s*\))/g;
+
 export abstract class SqlDatasource extends DataSourceWithBackend<SQLQuery, SQLOptions> {
   id: number;
   responseParser: ResponseParser;
@@ -100,6 +108,28 @@ export abstract class SqlDatasource extends DataSourceWithBackend<SQLQuery, SQLO
     return value;
   };
 
+  /**
+   * Renders the variables of the $__in, $__like and $__optionalFilter macros as a list of
+   * quoted literals, or as ALL_VALUES if All is selected, for the backend to expand.
+   */
+  interpolateValueMacros(rawSql: string, scopedVars: ScopedVars): string {
+    return rawSql.replace(VALUE_MACRO_REGEX, (_match: string, head: string, variable: string, tail: string) => {
+      const values = this.templateSrv.replace(
+        variable,
+        scopedVars,
+        (value: string | string[], v: VariableWithMultiSupport) => {
+          const current = v?.current?.value;
+          if (current === ALL_VALUES || (Array.isArray(current) && current.includes(ALL_VALUES))) {
+            return ALL_VALUES;
+          }
+          const list = Array.isArray(value) ? value : [value];
+          return list.map((x) => this.getQueryModel().quoteLiteral(String(x))).join(',');
+        }
+      );
+      return head + values + tail;
+    });
+  }
+
   interpolateVariablesInQueries(queries: SQLQuery[], scopedVars: ScopedVars): SQLQuery[] {
     let expandedQueries = queries;
     if (queries && queries.length > 0) {
@@ -107,7 +137,11 @@ export abstract class SqlDatasource extends DataSourceWithBackend<SQLQuery, SQLO
         const expandedQuery = {
           ...query,
           datasource: this.getRef(),
-          rawSql: this.templateSrv.replace(query.rawSql, scopedVars, this.interpolateVariable),
+          rawSql: this.templateSrv.replace(
+            this.interpolateValueMacros(query.rawSql ?? '', scopedVars),
+            scopedVars,
+            this.interpolateVariable
+          ),
           rawQuery: true,
         };
         return expandedQuery;
@@ -120,12 +154,31 @@ export abstract class SqlDatasource extends DataSourceWithBackend<SQLQuery, SQLO
     return !query.hide;
   }
 
-  applyTemplateVariables(target: SQLQuery, scopedVars: ScopedVars) {
+  applyTemplateVariables(target: SQLQuery, scopedVars: ScopedVars, filters?: AdHocVariableFilter[]) {
+    // keep the other query properties like the decimal mode or pivot options
     return {
-      refId: target.refId,
+      ...target,
       datasource: this.getRef(),
-      rawSql: this.templateSrv.replace(target.rawSql, scopedVars, this.interpolateVariable),
-      format: target.format,
+      rawSql: this.templateSrv.replace(
+        this.interpolateValueMacros(target.rawSql ?? '', scopedVars),
+        scopedVars,
+        this.interpolateVariable
+      ),
+      // ad hoc filters are applied by the backend to the result columns of the query
+      adhocFilters: filters?.length ? filters : undefined,
+      semantic: target.semantic && this.interpolateSemanticQuery(target.semantic, scopedVars),
+    };
+  }
+
+  // the values of the input parameters and filters of a semantic query may use template variables
+  interpolateSemanticQuery(semantic: SemanticQuery, scopedVars: ScopedVars): SemanticQuery {
+    const parameters = Object.fromEntries(
+      Object.entries(semantic.parameters ?? {}).map(([name, value]) => [name, this.templateSrv.replace(value, scopedVars)])
+    );
+    return {
+      ...semantic,
+      parameters,
+      filters: semantic.filters?.map((f) => ({ ...f, value: this.templateSrv.replace(f.value, scopedVars) })),
     };
   }
 
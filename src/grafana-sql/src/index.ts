@@ -4,8 +4,10 @@ export type {
   SQLExpression,
   SQLOptions,
   SQLQuery,
+  SemanticQuery,
   SqlQueryModel,
   SQLSelectableValue,
+  ValidationResults,
   Func,
   FuncParameter,
 } from './types';
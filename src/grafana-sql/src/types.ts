@@ -1,6 +1,7 @@
 import { JsonTree } from '@react-awesome-query-builder/ui';
 
 import {
+  AdHocVariableFilter,
   DataFrame,
   DataQuery,
   DataSourceJsonData,
@@ -49,7 +50,35 @@ export interface SQLOptions extends SQLConnectionLimits, DataSourceJsonData {
 
 export enum QueryFormat {
   Timeseries = 'time_series',
+  TimeseriesMulti = 'time_series_multi',
   Table = 'table',
+  Logs = 'logs',
+  LogsVolume = 'logs_volume',
+  Annotations = 'annotations',
+  Numeric = 'numeric',
+  Heatmap = 'heatmap',
+}
+
+export type PivotAggregate = 'sum' | 'avg' | 'min' | 'max' | 'count' | 'first' | 'last';
+
+export interface PivotOptions {
+  rows?: string[];
+  column: string;
+  value: string;
+  aggregate?: PivotAggregate;
+  maxColumns?: number;
+}
+
+// SemanticQuery queries the measures of a calculation view, the backend compiles it to SQL.
+export interface SemanticQuery {
+  schema?: string;
+  view: string;
+  parameters?: Record<string, string>;
+  measures: string[];
+  dimensions?: string[];
+  timeDimension?: { name: string; interval?: string; fill?: string };
+  filters?: AdHocVariableFilter[];
+  limit?: number;
 }
 
 export interface SQLQuery extends DataQuery {
@@ -61,6 +90,11 @@ export interface SQLQuery extends DataQuery {
   sql?: SQLExpression;
   editorMode?: EditorMode;
   rawQuery?: boolean;
+  pivot?: PivotOptions;
+  adhocFilters?: AdHocVariableFilter[];
+  semantic?: SemanticQuery;
+  /** Return the explain plan as second frame and execution statistics in the frame metadata. */
+  explain?: boolean;
 }
 
 export interface NameValue {
@@ -104,7 +138,12 @@ export interface QueryRowFilter {
 
 export const QUERY_FORMAT_OPTIONS = [
   { label: 'Time series', value: QueryFormat.Timeseries },
+  { label: 'Time series (multi frame)', value: QueryFormat.TimeseriesMulti },
   { label: 'Table', value: QueryFormat.Table },
+  { label: 'Logs', value: QueryFormat.Logs },
+  { label: 'Annotations', value: QueryFormat.Annotations },
+  { label: 'Numeric', value: QueryFormat.Numeric },
+  { label: 'Heatmap', value: QueryFormat.Heatmap },
 ];
 
 const backWardToOption = (value: string) => ({ label: value, value });
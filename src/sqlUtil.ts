@@ -72,6 +72,17 @@ export function quoteLiteral(value: string) {
   return "'" + value.replace(/'/g, "''") + "'";
 }
 
+// build the PLACEHOLDER clause passing input parameter values to a calculation view
+export function buildPlaceholderClause(values: Record<string, string>) {
+  const parts = Object.keys(values)
+    .sort()
+    .map((name) => {
+      const placeholder = name.startsWith('$$') ? name : `$$${name}$$`;
+      return `'PLACEHOLDER' = (${quoteLiteral(placeholder)}, ${quoteLiteral(values[name])})`;
+    });
+  return parts.length ? `(${parts.join(', ')})` : '';
+}
+
 /**
  * SELECT * FROM RESERVED_KEYWORDS ORDER BY RESERVED_KEYWORD
  */
@@ -2,6 +2,94 @@ import { SQLOptions, SQLQuery } from 'grafana-sql';
 
 export interface HANAOptions extends SQLOptions {
   allowCleartextPasswords?: boolean;
+  maxFrameBytes?: number;
+  lobMaxLength?: number;
+  metadataCacheTTL?: number;
+  decimalMode?: 'float64' | 'int64' | 'scaled' | 'string';
+  maxEstimatedCost?: number;
+  maxEstimatedRows?: number;
+  costGuardExemptDashboards?: string[];
+  costGuardExemptUsers?: string[];
+  allowedSchemas?: string[];
+  allowedTables?: string[];
+  maxTimeRange?: string;
+  minRefreshInterval?: string;
+  rowScopes?: RowScope[];
+}
+
+// RowScope restricts the rows the matching users may read to the values of a column.
+export interface RowScope {
+  users?: string[];
+  roles?: string[];
+  column: string;
+  values: string[];
 }
 
 export interface HANAQuery extends SQLQuery { }
+
+// CatalogItem is an object listed by the catalog resources of the backend.
+export interface CatalogItem {
+  name: string;
+  type?: string;
+  comment?: string;
+}
+
+export interface CatalogResponse<T = CatalogItem> {
+  schema?: string;
+  items: T[];
+  limit: number;
+  offset: number;
+  hasMore: boolean;
+}
+
+export interface CalcViewValue {
+  value: string;
+  description?: string;
+}
+
+// CalcViewParameter is an input parameter or variable of a calculation view.
+export interface CalcViewParameter {
+  name: string;
+  placeholder: string;
+  description?: string;
+  column?: string;
+  type?: string;
+  mandatory: boolean;
+  default?: string;
+  selectionType?: string;
+  multiple: boolean;
+  values?: CalcViewValue[];
+}
+
+export interface CalcViewSemantics {
+  schema: string;
+  measures: Array<{ name: string; aggregation: string }>;
+  dimensions: Array<{ name: string; type: string }>;
+}
+
+// BuilderQuery is a query of the visual editor compiled to SQL by the backend.
+export interface BuilderQuery {
+  schema?: string;
+  table: string;
+  columns: Array<{ name: string; function?: string; parameters?: string[]; alias?: string }>;
+  where?: BuilderCondition[];
+  conjunction?: string;
+  groupBy?: string[];
+  orderBy?: Array<{ column: string; descending?: boolean }>;
+  limit?: number;
+}
+
+export interface BuilderCondition {
+  column: string;
+  operator: string;
+  value?: string;
+  values?: string[];
+}
+
+// ValidateResponse is the result of the validate resource, the query is prepared but not executed.
+export interface ValidateResponse {
+  valid: boolean;
+  sql: string;
+  columns?: Array<{ name: string; type: string; nullable: boolean }>;
+  error?: { message: string; stage: string; code?: number; position?: number; line?: number; column?: number };
+}